@@ -0,0 +1,154 @@
+package gjsonpack
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PackBatch 压缩多个文档, 文档共享同一个字典, 每个文档有自己的结构区, 并写入结构区的偏移表,
+// 格式为 "版本头^strings^integers^floats^偏移表^结构区..."
+func PackBatch(documents []interface{}, opts ...PackOption) (string, error) {
+	options := NewPackOptions(opts...)
+	var dictionaryObj dictionary
+	dictionaryObj.Strings = make(dictionaryString, 0)
+	dictionaryObj.Integers = make(dictionaryIntegers, 0)
+	dictionaryObj.Floats = make(dictionaryFloat, 0)
+	state := _newPackState(&dictionaryObj, &options)
+	astTrees := make([]ast, len(documents))
+	for i, document := range documents {
+		if options.JSCompat {
+			jsValue, jsValueErr := _jsCompatValue(document)
+			if jsValueErr != nil {
+				return "", jsValueErr
+			}
+			document = jsValue
+		}
+		// Every document has its own anchors
+		state.anchors = make(map[visitKey]*astAnchor)
+		state.path = append(state.path[:0], "["+strconv.Itoa(i)+"]")
+		astTree, astTreeErr := recursiveAstBuilder(document, state)
+		if astTreeErr != nil {
+			return "", astTreeErr
+		}
+		astTrees[i] = astTree
+	}
+	var stringLength = dictionaryObj.Strings.Len()
+	var integerLength = dictionaryObj.Integers.Len()
+	var floatLength = dictionaryObj.Floats.Len()
+	var packed = strings.Join(dictionaryObj.Strings, "|")
+	packed += "^" + strings.Join(dictionaryObj.Integers, "|")
+	if options.JSCompat {
+		packed += "^" + strings.Join(_arrayFloatToJSArrayString(dictionaryObj.Floats), "|")
+	} else {
+		packed += "^" + strings.Join(_arrayFloatToArrayString(dictionaryObj.Floats), "|")
+	}
+	features := FeatureBatch
+	offsets := make([]string, len(astTrees))
+	var structures strings.Builder
+	for i, astTree := range astTrees {
+		var anchorIndex int64
+		structure, structureErr := recursiveParser(astTree, stringLength, integerLength, floatLength, &anchorIndex)
+		if structureErr != nil {
+			return "", structureErr
+		}
+		if anchorIndex > 0 {
+			features |= FeatureGraph
+		}
+		offsets[i] = _baseInt10To36(int64(structures.Len()))
+		structures.WriteString(structure)
+	}
+	packed += "^" + strings.Join(offsets, "|") + "^" + structures.String()
+	return _wrapPacked(packed, packedHeader{Features: features}, &options)
+}
+
+// Batch PackBatch 压缩的多个文档, 字典只解析一次, 每个文档按需解析
+type Batch struct {
+	state *unpackState
+	// The start of every structure in the structures section
+	offsets    []int64
+	structures string
+	// The byte offset of the structures section
+	structuresOffset int64
+}
+
+// ParseBatch 解析 PackBatch 压缩的数据
+func ParseBatch(packed string, opts ...UnpackOption) (*Batch, error) {
+	options := NewUnpackOptions(opts...)
+	state, stateErr := _unpackDictionaries(packed, &options)
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	if state.header.Features&FeatureBatch == 0 || len(state.sections) != 5 {
+		return nil, errors.New("The packed data isn't a batch! ")
+	}
+	batch := &Batch{
+		state:            state,
+		structures:       state.sections[4],
+		structuresOffset: state.structureOffset + int64(len(state.sections[3])) + 1,
+	}
+	var previous int64
+	for _, offset36 := range strings.Split(state.sections[3], "|") {
+		if offset36 == "" {
+			// An empty batch
+			break
+		}
+		offset, offsetErr := _baseString36To10(offset36)
+		if offsetErr != nil {
+			return nil, offsetErr
+		}
+		if offset < previous || offset > int64(len(batch.structures)) {
+			return nil, fmt.Errorf("Bad offset %s isn't a efficient range! ", offset36)
+		}
+		batch.offsets = append(batch.offsets, offset)
+		previous = offset
+	}
+	return batch, nil
+}
+
+// Len 文档数
+func (b *Batch) Len() int {
+	return len(b.offsets)
+}
+
+// document 解析第 i 个文档的结构区, 不会读取其他文档
+func (b *Batch) document(i int) (*unpackState, error) {
+	if i < 0 || i >= len(b.offsets) {
+		return nil, fmt.Errorf("Bad document %d isn't a efficient range! ", i)
+	}
+	end := int64(len(b.structures))
+	if i+1 < len(b.offsets) {
+		end = b.offsets[i+1]
+	}
+	// The dictionaries are shared, the structure state is per document
+	state := &unpackState{
+		options:         b.state.options,
+		header:          b.state.header,
+		sections:        b.state.sections,
+		dictionarySlice: b.state.dictionarySlice,
+		dictionarySizes: b.state.dictionarySizes,
+	}
+	if err := state.parseStructure(b.structures[b.offsets[i]:end], b.structuresOffset+b.offsets[i]); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Unpack 将第 i 个文档解压到 v
+func (b *Batch) Unpack(i int, v interface{}) error {
+	state, stateErr := b.document(i)
+	if stateErr != nil {
+		return stateErr
+	}
+	return b.state.options.decode(state, v)
+}
+
+// UnpackToBytes 解压第 i 个文档并返回 JSON
+func (b *Batch) UnpackToBytes(i int) ([]byte, error) {
+	state, stateErr := b.document(i)
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	return b.state.options.marshal(state.result)
+}
@@ -0,0 +1,103 @@
+package gjsonpack
+
+import (
+	"strings"
+	"testing"
+)
+
+// Documents share one dictionary and unpack one at a time
+func TestPackBatch(t *testing.T) {
+	documents := []interface{}{
+		map[string]interface{}{"type": "world", "name": "earth"},
+		map[string]interface{}{"type": "world", "name": "mars", "moons": 2},
+		[]interface{}{"earth", 1.5, true},
+		"mars",
+	}
+	packStr, packErr := PackBatch(documents, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "%GJP1.W^name|earth|type|world|moons|mars^2^1.5^0|9|M|U^$0|1|2|3]$4|6|0|5|2|3]@1|7|-1]5" {
+		t.Fatalf("unexpected packed %s", packStr)
+	}
+	batch, batchErr := ParseBatch(packStr)
+	if batchErr != nil {
+		t.Fatal(batchErr)
+	}
+	if batch.Len() != 4 {
+		t.Fatalf("unexpected length %d", batch.Len())
+	}
+	for i, expected := range []string{
+		`{"name":"earth","type":"world"}`,
+		`{"moons":2,"name":"mars","type":"world"}`,
+		`["earth",1.5,true]`,
+		`"mars"`,
+	} {
+		jsonBytes, unPackErr := batch.UnpackToBytes(i)
+		if unPackErr != nil {
+			t.Fatal(unPackErr)
+		}
+		if string(jsonBytes) != expected {
+			t.Fatalf("document %d: unexpected %s", i, jsonBytes)
+		}
+	}
+	var planet struct {
+		Name  string `json:"name"`
+		Moons int    `json:"moons"`
+	}
+	if err := batch.Unpack(1, &planet); err != nil || planet.Name != "mars" || planet.Moons != 2 {
+		t.Fatalf("unexpected planet %+v: %v", planet, err)
+	}
+	if err := batch.Unpack(4, &planet); err == nil {
+		t.Fatal("expected an error for a missing document")
+	}
+	// A batch isn't a single document
+	if _, err := UnpackToStr(packStr); err == nil {
+		t.Fatal("expected an error for unpacking a batch")
+	}
+	if _, err := ParseBatch("a^^^0"); err == nil {
+		t.Fatal("expected an error for a single document")
+	}
+}
+
+// A broken document doesn't affect the others
+func TestBatchRandomAccess(t *testing.T) {
+	packStr, _ := PackBatch([]interface{}{[]interface{}{"a"}, []interface{}{"b"}}, WithCompression(CompressionFlate), WithChecksum())
+	batch, batchErr := ParseBatch(packStr)
+	if batchErr != nil {
+		t.Fatal(batchErr)
+	}
+	if jsonBytes, err := batch.UnpackToBytes(1); err != nil || string(jsonBytes) != `["b"]` {
+		t.Fatalf("unexpected %s: %v", jsonBytes, err)
+	}
+	broken, _ := ParseBatch("%GJP1.W^a|b^^^0|3^@0]@1|z]")
+	if jsonBytes, err := broken.UnpackToBytes(0); err != nil || string(jsonBytes) != `["a"]` {
+		t.Fatalf("unexpected %s: %v", jsonBytes, err)
+	}
+	if _, err := broken.UnpackToBytes(1); err == nil {
+		t.Fatal("expected an error for the broken document")
+	}
+	if _, err := ParseBatch("%GJP1.W^a^^^0|9^@0]"); err == nil || !strings.Contains(err.Error(), "offset") {
+		t.Fatalf("expected a bad offset, got %v", err)
+	}
+	// Shared pointers are anchored per document
+	node := &graphNode{Name: "shared"}
+	graphStr, _ := PackBatch([]interface{}{[]*graphNode{node, node}, node}, WithGraph())
+	graphBatch, _ := ParseBatch(graphStr)
+	if jsonBytes, err := graphBatch.UnpackToBytes(1); err != nil || string(jsonBytes) != `{"children":[],"name":"shared"}` {
+		t.Fatalf("unexpected %s: %v", jsonBytes, err)
+	}
+	empty, emptyErr := ParseBatch(mustPackBatch(t, nil))
+	if emptyErr != nil || empty.Len() != 0 {
+		t.Fatalf("unexpected empty batch %v", emptyErr)
+	}
+}
+
+// mustPackBatch 压缩 documents, 失败时终止测试
+func mustPackBatch(t *testing.T, documents []interface{}) string {
+	packStr, packErr := PackBatch(documents)
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	return packStr
+}
@@ -0,0 +1,362 @@
+// gjsonpack-gen 为结构体生成不使用反射的 PackGJSON、UnpackGJSON 方法, 输出与反射的压缩逐字节一致:
+//
+//	//go:generate go run github.com/JoiLa/gjsonpack/cmd/gjsonpack-gen -type Article,Author
+//
+// 参数为源文件或目录, 默认为当前目录, 目录中的 _test.go 文件会被忽略
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// basicKinds 可以直接写入的内置类型
+var basicKinds = map[string]string{
+	"string":  "string",
+	"bool":    "bool",
+	"int":     "int",
+	"int8":    "int",
+	"int16":   "int",
+	"int32":   "int",
+	"int64":   "int",
+	"rune":    "int",
+	"uint":    "uint",
+	"uint8":   "uint",
+	"uint16":  "uint",
+	"uint32":  "uint",
+	"uint64":  "uint",
+	"uintptr": "uint",
+	"byte":    "uint",
+	"float32": "float",
+	"float64": "float",
+}
+
+// packMethods 各类型写入时使用的 Encoder 方法及转换
+var packMethods = map[string][2]string{
+	"string": {"String", "string"},
+	"bool":   {"Bool", "bool"},
+	"int":    {"Int", "int64"},
+	"uint":   {"Uint", "uint64"},
+	"float":  {"Float", "float64"},
+}
+
+// unpackFunctions 内置类型解压时使用的函数, 其他类型使用 UnpackValue
+var unpackFunctions = map[string]string{
+	"string":  "UnpackString",
+	"bool":    "UnpackBool",
+	"int":     "UnpackInt",
+	"int64":   "UnpackInt64",
+	"uint64":  "UnpackUint64",
+	"float64": "UnpackFloat64",
+}
+
+// field 参与压缩的字段
+type field struct {
+	// Name 字段在 Go 中的名称
+	Name string
+	// Key 字段在 JSON 中的名称
+	Key string
+	// Type 字段的类型
+	Type ast.Expr
+	// OmitEmpty 标签带有 omitempty
+	OmitEmpty bool
+}
+
+// generator 源文件中的类型
+type generator struct {
+	packageName string
+	types       map[string]*ast.TypeSpec
+}
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names, required")
+	output := flag.String("output", "", "output file name, default <type>_gjsonpack.go next to the sources")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gjsonpack-gen -type T[,T...] [-output file] [file.go|directory ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"."}
+	}
+	if err := run(strings.Split(*typeNames, ","), inputs, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "gjsonpack-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run 解析 inputs 并将 typeNames 的方法写入 output
+func run(typeNames []string, inputs []string, output string) error {
+	files, filesErr := _sourceFiles(inputs)
+	if filesErr != nil {
+		return filesErr
+	}
+	g, generatorErr := _newGenerator(files)
+	if generatorErr != nil {
+		return generatorErr
+	}
+	source, sourceErr := g.generate(typeNames)
+	if sourceErr != nil {
+		return sourceErr
+	}
+	if output == "" {
+		output = strings.ToLower(typeNames[0]) + "_gjsonpack.go"
+		if strings.HasSuffix(files[0], "_test.go") {
+			output = strings.ToLower(typeNames[0]) + "_gjsonpack_test.go"
+		}
+		output = filepath.Join(filepath.Dir(files[0]), output)
+	}
+	return os.WriteFile(output, source, 0644)
+}
+
+// _sourceFiles 展开 inputs 中的目录
+func _sourceFiles(inputs []string) ([]string, error) {
+	var files []string
+	for _, input := range inputs {
+		info, infoErr := os.Stat(input)
+		if infoErr != nil {
+			return nil, infoErr
+		}
+		if !info.IsDir() {
+			files = append(files, input)
+			continue
+		}
+		matches, matchesErr := filepath.Glob(filepath.Join(input, "*.go"))
+		if matchesErr != nil {
+			return nil, matchesErr
+		}
+		for _, match := range matches {
+			if !strings.HasSuffix(match, "_test.go") && !strings.HasSuffix(match, "_gjsonpack.go") {
+				files = append(files, match)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no Go source files")
+	}
+	return files, nil
+}
+
+// _newGenerator 解析 files 中的类型
+func _newGenerator(files []string) (*generator, error) {
+	g := &generator{types: make(map[string]*ast.TypeSpec)}
+	fileSet := token.NewFileSet()
+	for _, file := range files {
+		parsed, parsedErr := parser.ParseFile(fileSet, file, nil, parser.ParseComments)
+		if parsedErr != nil {
+			return nil, parsedErr
+		}
+		if g.packageName == "" {
+			g.packageName = parsed.Name.Name
+		} else if g.packageName != parsed.Name.Name {
+			return nil, fmt.Errorf("%s is in package %s, not %s", file, parsed.Name.Name, g.packageName)
+		}
+		for _, decl := range parsed.Decls {
+			genDecl, isGenDecl := decl.(*ast.GenDecl)
+			if !isGenDecl || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				g.types[typeSpec.Name.Name] = typeSpec
+			}
+		}
+	}
+	return g, nil
+}
+
+// generate 生成 typeNames 的方法
+func (g *generator) generate(typeNames []string) ([]byte, error) {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "// Code generated by gjsonpack-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buffer, "package %s\n\nimport \"github.com/JoiLa/gjsonpack\"\n", g.packageName)
+	for _, typeName := range typeNames {
+		typeName = strings.TrimSpace(typeName)
+		typeSpec, typeSpecExists := g.types[typeName]
+		if !typeSpecExists {
+			return nil, fmt.Errorf("type %s not found", typeName)
+		}
+		structType, isStruct := typeSpec.Type.(*ast.StructType)
+		if !isStruct || typeSpec.Assign.IsValid() {
+			return nil, fmt.Errorf("type %s isn't a struct", typeName)
+		}
+		fields, fieldsErr := _structFields(structType)
+		if fieldsErr != nil {
+			return nil, fmt.Errorf("type %s: %v", typeName, fieldsErr)
+		}
+		g.generatePack(&buffer, typeName, fields)
+		g.generateUnpack(&buffer, typeName, fields)
+	}
+	source, sourceErr := format.Source(buffer.Bytes())
+	if sourceErr != nil {
+		return nil, fmt.Errorf("formatting the generated code: %v", sourceErr)
+	}
+	return source, nil
+}
+
+// generatePack 生成 PackGJSON, 字段顺序与反射一致
+func (g *generator) generatePack(buffer *bytes.Buffer, typeName string, fields []field) {
+	fmt.Fprintf(buffer, "\n// PackGJSON 不使用反射压缩 %s\n", typeName)
+	fmt.Fprintf(buffer, "func (v %s) PackGJSON(e *gjsonpack.Encoder) error {\n", typeName)
+	fmt.Fprintf(buffer, "e.BeginObject()\n")
+	for _, f := range fields {
+		condition := ""
+		if f.OmitEmpty {
+			condition = g.nonEmpty(f)
+		}
+		if condition != "" {
+			fmt.Fprintf(buffer, "if %s {\n", condition)
+		}
+		fmt.Fprintf(buffer, "e.Key(%s)\n", strconv.Quote(f.Key))
+		if kind := g.basicKind(f.Type, 0); kind != "" {
+			method := packMethods[kind]
+			if ident, isIdent := f.Type.(*ast.Ident); isIdent && ident.Name == method[1] {
+				fmt.Fprintf(buffer, "e.%s(v.%s)\n", method[0], f.Name)
+			} else {
+				fmt.Fprintf(buffer, "e.%s(%s(v.%s))\n", method[0], method[1], f.Name)
+			}
+		} else {
+			fmt.Fprintf(buffer, "if err := e.Value(v.%s); err != nil {\nreturn err\n}\n", f.Name)
+		}
+		if condition != "" {
+			fmt.Fprintf(buffer, "}\n")
+		}
+	}
+	fmt.Fprintf(buffer, "e.End()\nreturn nil\n}\n")
+}
+
+// generateUnpack 生成 UnpackGJSON
+func (g *generator) generateUnpack(buffer *bytes.Buffer, typeName string, fields []field) {
+	fmt.Fprintf(buffer, "\n// UnpackGJSON 不使用反射解压 %s\n", typeName)
+	fmt.Fprintf(buffer, "func (v *%s) UnpackGJSON(value interface{}) error {\n", typeName)
+	fmt.Fprintf(buffer, "object, objectErr := gjsonpack.UnpackObject(value, v)\n")
+	fmt.Fprintf(buffer, "if object == nil || objectErr != nil {\nreturn objectErr\n}\n")
+	for _, f := range fields {
+		function := "UnpackValue"
+		if ident, isIdent := f.Type.(*ast.Ident); isIdent && g.types[ident.Name] == nil && unpackFunctions[ident.Name] != "" {
+			function = unpackFunctions[ident.Name]
+		}
+		fmt.Fprintf(buffer, "if item, itemExists := gjsonpack.LookupField(object, %s); itemExists {\n", strconv.Quote(f.Key))
+		fmt.Fprintf(buffer, "if err := gjsonpack.%s(item, &v.%s); err != nil {\nreturn err\n}\n}\n", function, f.Name)
+	}
+	fmt.Fprintf(buffer, "return nil\n}\n")
+}
+
+// nonEmpty 返回 omitempty 字段不为空的条件, 规则与 encoding/json 一致, 结构体从不为空时返回 ""
+func (g *generator) nonEmpty(f field) string {
+	switch g.basicKind(f.Type, 0) {
+	case "string":
+		return "v." + f.Name + ` != ""`
+	case "bool":
+		return "v." + f.Name
+	case "int", "uint", "float":
+		return "v." + f.Name + " != 0"
+	}
+	switch g.underlying(f.Type, 0).(type) {
+	case *ast.ArrayType, *ast.MapType:
+		return "len(v." + f.Name + ") != 0"
+	case *ast.StarExpr, *ast.InterfaceType:
+		return "v." + f.Name + " != nil"
+	case *ast.StructType:
+		return ""
+	}
+	// A type of another package
+	return "!gjsonpack.IsEmpty(v." + f.Name + ")"
+}
+
+// underlying 展开本包中定义的类型
+func (g *generator) underlying(expr ast.Expr, depth int) ast.Expr {
+	if ident, isIdent := expr.(*ast.Ident); isIdent && depth <= 8 {
+		if typeSpec, typeSpecExists := g.types[ident.Name]; typeSpecExists {
+			return g.underlying(typeSpec.Type, depth+1)
+		}
+	}
+	return expr
+}
+
+// basicKind 类型为内置类型或以内置类型定义的本包类型时返回其种类
+func (g *generator) basicKind(expr ast.Expr, depth int) string {
+	ident, isIdent := expr.(*ast.Ident)
+	if !isIdent || depth > 8 {
+		return ""
+	}
+	if typeSpec, typeSpecExists := g.types[ident.Name]; typeSpecExists {
+		return g.basicKind(typeSpec.Type, depth+1)
+	}
+	return basicKinds[ident.Name]
+}
+
+// _structFields 按 json 标签获取参与压缩的字段, 规则与 gjsonpack 的反射一致
+func _structFields(structType *ast.StructType) ([]field, error) {
+	var fields []field
+	for _, astField := range structType.Fields.List {
+		var tag reflect.StructTag
+		if astField.Tag != nil {
+			unquoted, unquotedErr := strconv.Unquote(astField.Tag.Value)
+			if unquotedErr != nil {
+				return nil, unquotedErr
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		names := astField.Names
+		if len(names) == 0 {
+			// An embedded field is named after its type
+			name := _embeddedName(astField.Type)
+			if name == nil {
+				return nil, fmt.Errorf("unsupported embedded field %s", types.ExprString(astField.Type))
+			}
+			names = []*ast.Ident{name}
+		}
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			key := tag.Get("json")
+			if key == "-" {
+				continue
+			}
+			var omitEmpty bool
+			if comma := strings.Index(key, ","); comma >= 0 {
+				for _, option := range strings.Split(key[comma+1:], ",") {
+					omitEmpty = omitEmpty || option == "omitempty"
+				}
+				key = key[:comma]
+			}
+			if key == "" {
+				key = name.Name
+			}
+			fields = append(fields, field{Name: name.Name, Key: key, Type: astField.Type, OmitEmpty: omitEmpty})
+		}
+	}
+	return fields, nil
+}
+
+// _embeddedName 嵌入字段的名称
+func _embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return _embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	}
+	return nil
+}
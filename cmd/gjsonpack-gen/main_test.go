@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The generated test file of the gjsonpack package is up to date
+func TestGenerateUpToDate(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "codegen_gjsonpack_test.go")
+	if err := run([]string{"genArticle", "genAuthor"}, []string{"../../codegen_test.go"}, output); err != nil {
+		t.Fatal(err)
+	}
+	generated, _ := os.ReadFile(output)
+	committed, committedErr := os.ReadFile("../../codegen_gjsonpack_test.go")
+	if committedErr != nil {
+		t.Fatal(committedErr)
+	}
+	if string(generated) != string(committed) {
+		t.Fatal("codegen_gjsonpack_test.go is stale, run go generate")
+	}
+}
+
+// Unknown and non-struct types are rejected
+func TestGenerateErrors(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "types.go")
+	if err := os.WriteFile(source, []byte("package types\n\ntype Level int\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"Missing"}, []string{source}, ""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a missing type, got %v", err)
+	}
+	if err := run([]string{"Level"}, []string{source}, ""); err == nil || !strings.Contains(err.Error(), "isn't a struct") {
+		t.Fatalf("expected a non-struct type, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+// gjsonpack 命令行工具, 压缩与解压文件或标准输入中的数据:
+//
+//	gjsonpack pack [-sort] [-js] [-o file] [file]
+//	gjsonpack unpack [-pretty] [-o file] [file]
+//	gjsonpack inspect [file]
+//
+// 没有文件参数或文件为 "-" 时读取标准输入, 没有 -o 时写入标准输出
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: gjsonpack <command> [flags] [file]
+
+Commands:
+  pack     pack JSON into a gjsonpack string
+  unpack   unpack a gjsonpack string into JSON
+  inspect  show the dictionaries and the structure of a gjsonpack string
+
+Run "gjsonpack <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 执行命令并返回退出码
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	var command func([]string, io.Reader, io.Writer, io.Writer) int
+	switch args[0] {
+	case "pack":
+		command = runPack
+	case "unpack":
+		command = runUnpack
+	case "inspect":
+		command = runInspect
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "gjsonpack: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return command(args[1:], stdin, stdout, stderr)
+}
+
+// runPack 压缩 JSON
+func runPack(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	sortKeys := flags.Bool("sort", false, "sort object keys for a deterministic output")
+	jsCompat := flags.Bool("js", false, "match the output of the JavaScript jsonpack library")
+	output := flags.String("o", "", "output file, default stdout")
+	input, ok := parseFlags(flags, args)
+	if !ok {
+		return exitUsage
+	}
+	data, readErr := readInput(input, stdin)
+	if readErr != nil {
+		return fail(stderr, readErr)
+	}
+	var packed string
+	var packErr error
+	if *jsCompat {
+		// The JSON text is parsed like JSON.parse
+		packed, packErr = gjsonpack.PackWithOptions(json.RawMessage(data), gjsonpack.WithJSCompat())
+	} else {
+		// Keep the numbers as they are written, integers beyond 2^53 lose their precision in float64
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return fail(stderr, fmt.Errorf("invalid JSON: %v", err))
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return fail(stderr, errors.New("invalid JSON: data after the top-level value"))
+		}
+		var opts []gjsonpack.PackOption
+		if *sortKeys {
+			opts = append(opts, gjsonpack.WithSortedKeys())
+		}
+		packed, packErr = gjsonpack.PackWithOptions(value, opts...)
+	}
+	if packErr != nil {
+		return fail(stderr, packErr)
+	}
+	if err := writeOutput(*output, stdout, []byte(packed+"\n")); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// runUnpack 解压为 JSON
+func runUnpack(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	pretty := flags.Bool("pretty", false, "indent the JSON output")
+	output := flags.String("o", "", "output file, default stdout")
+	input, ok := parseFlags(flags, args)
+	if !ok {
+		return exitUsage
+	}
+	data, readErr := readInput(input, stdin)
+	if readErr != nil {
+		return fail(stderr, readErr)
+	}
+	jsonBytes, unpackErr := gjsonpack.UnpackToBytes(strings.TrimSpace(string(data)))
+	if unpackErr != nil {
+		return fail(stderr, unpackErr)
+	}
+	if *pretty {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, jsonBytes, "", "  "); err != nil {
+			return fail(stderr, err)
+		}
+		jsonBytes = indented.Bytes()
+	}
+	if err := writeOutput(*output, stdout, append(jsonBytes, '\n')); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// runInspect 输出字典与结构的组成
+func runInspect(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	input, ok := parseFlags(flags, args)
+	if !ok {
+		return exitUsage
+	}
+	data, readErr := readInput(input, stdin)
+	if readErr != nil {
+		return fail(stderr, readErr)
+	}
+	inspection, inspectErr := gjsonpack.Inspect(strings.TrimSpace(string(data)))
+	if inspectErr != nil {
+		return fail(stderr, inspectErr)
+	}
+	var report bytes.Buffer
+	if inspection.Version > 0 {
+		fmt.Fprintf(&report, "header: version %d, features %#x\n\n", inspection.Version, int64(inspection.Features))
+	}
+	writeSection(&report, "strings", inspection.Strings, inspection.StringsSize)
+	writeSection(&report, "integers", inspection.Integers, inspection.IntegersSize)
+	writeSection(&report, "floats", inspection.Floats, inspection.FloatsSize)
+	fmt.Fprintf(&report, "structure: %d tokens, %d bytes\n\n", inspection.Tokens, inspection.StructureSize)
+	fmt.Fprintf(&report, "packed size: %d bytes\n", inspection.PackedSize)
+	if inspection.JSONSize < 0 {
+		fmt.Fprintf(&report, "minified JSON: not available, the data has cycles\n")
+	} else {
+		fmt.Fprintf(&report, "minified JSON: %d bytes\n", inspection.JSONSize)
+		fmt.Fprintf(&report, "ratio: %.1f%%\n", inspection.Ratio()*100)
+	}
+	if _, err := stdout.Write(report.Bytes()); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// writeSection 输出一个字典区
+func writeSection(report *bytes.Buffer, name string, entries []gjsonpack.DictionaryEntry, size int) {
+	fmt.Fprintf(report, "%s: %d entries, %d bytes\n", name, len(entries), size)
+	for _, entry := range entries {
+		value, _ := json.Marshal(entry.Value)
+		fmt.Fprintf(report, "  %-6s %-30s refs %d\n", strconv.FormatInt(entry.Index, 36), value, entry.Refs)
+	}
+	fmt.Fprintln(report)
+}
+
+// parseFlags 解析参数, 返回输入文件
+func parseFlags(flags *flag.FlagSet, args []string) (string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", false
+	}
+	switch flags.NArg() {
+	case 0:
+		return "-", true
+	case 1:
+		return flags.Arg(0), true
+	}
+	fmt.Fprintf(flags.Output(), "gjsonpack %s: expected at most one input file\n", flags.Name())
+	return "", false
+}
+
+// readInput 读取文件, "-" 为标准输入
+func readInput(input string, stdin io.Reader) ([]byte, error) {
+	if input == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(input)
+}
+
+// writeOutput 写入文件, 没有文件时写入标准输出
+func writeOutput(output string, stdout io.Writer, data []byte) error {
+	if output == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(output, data, 0644)
+}
+
+// fail 输出错误并返回错误的退出码
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "gjsonpack: %v\n", err)
+	return exitError
+}
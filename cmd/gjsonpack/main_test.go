@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runWith 以 stdin 执行命令
+func runWith(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// Pack and unpack through stdin and stdout
+func TestPackUnpack(t *testing.T) {
+	code, packed, stderr := runWith(`{"type":"world","name":"earth","size":[1,2.5]}`, "pack", "-sort")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if packed != "name|earth|size|type|world^1^2.5^$0|1|2|@5|6]|3|4]\n" {
+		t.Fatalf("unexpected packed %q", packed)
+	}
+	code, unpacked, stderr := runWith(packed, "unpack")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if unpacked != `{"name":"earth","size":[1,2.5],"type":"world"}`+"\n" {
+		t.Fatalf("unexpected unpacked %q", unpacked)
+	}
+	code, unpacked, _ = runWith(packed, "unpack", "-pretty")
+	if code != exitOK || !strings.HasPrefix(unpacked, "{\n  \"name\": \"earth\",") {
+		t.Fatalf("unexpected pretty %q", unpacked)
+	}
+	code, packed, _ = runWith(`{"b":1,"a":2}`, "pack", "-js")
+	if code != exitOK || packed != "b|a^1|2^^$0|2|1|3]\n" {
+		t.Fatalf("unexpected js packed %q", packed)
+	}
+}
+
+// Integers beyond 2^53 keep their precision
+func TestPackLargeIntegers(t *testing.T) {
+	code, packed, stderr := runWith(`{"id":9007199254740993,"ratio":0.5}`, "pack", "-sort")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	code, unpacked, stderr := runWith(packed, "unpack")
+	if code != exitOK || unpacked != `{"id":9007199254740993,"ratio":0.5}`+"\n" {
+		t.Fatalf("exit %d: unexpected unpacked %q %s", code, unpacked, stderr)
+	}
+	// Beyond int64 they are kept as floats
+	code, packed, stderr = runWith(`{"big":100000000000000000000}`, "pack")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	code, unpacked, stderr = runWith(packed, "unpack")
+	if code != exitOK || unpacked != `{"big":100000000000000000000}`+"\n" {
+		t.Fatalf("exit %d: unexpected unpacked %q %s", code, unpacked, stderr)
+	}
+}
+
+// Read and write files
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.json")
+	packedFile := filepath.Join(dir, "out.gjp")
+	if err := os.WriteFile(input, []byte(`["hello world"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runWith("", "pack", "-o", packedFile, input); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	code, unpacked, stderr := runWith("", "unpack", packedFile)
+	if code != exitOK || unpacked != `["hello world"]`+"\n" {
+		t.Fatalf("exit %d %q: %s", code, unpacked, stderr)
+	}
+}
+
+// Errors and usage
+func TestExitCodes(t *testing.T) {
+	for _, test := range []struct {
+		stdin string
+		args  []string
+		code  int
+	}{
+		{"", nil, exitUsage},
+		{"", []string{"zip"}, exitUsage},
+		{"", []string{"pack", "-unknown"}, exitUsage},
+		{"", []string{"pack", "a", "b"}, exitUsage},
+		{"{", []string{"pack"}, exitError},
+		{"{} {}", []string{"pack"}, exitError},
+		{"x", []string{"unpack"}, exitError},
+		{"", []string{"unpack", filepath.Join(t.TempDir(), "missing")}, exitError},
+		{"", []string{"help"}, exitOK},
+	} {
+		if code, _, stderr := runWith(test.stdin, test.args...); code != test.code {
+			t.Fatalf("%v: expected exit %d, got %d: %s", test.args, test.code, code, stderr)
+		}
+	}
+}
+
+// Inspect the dictionaries and the structure
+func TestInspect(t *testing.T) {
+	code, report, stderr := runWith("list|world|type^1|1^2.5^$0|@1|3|4|5|-1]|2|1]\n", "inspect")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	for _, line := range []string{
+		"strings: 3 entries, 15 bytes\n",
+		"  1      \"world\"                        refs 2\n",
+		"integers: 2 entries, 3 bytes\n",
+		"  5      2.5                            refs 1\n",
+		"structure: 12 tokens, 20 bytes\n",
+		"packed size: 44 bytes\nminified JSON: 46 bytes\nratio: 95.7%\n",
+	} {
+		if !strings.Contains(report, line) {
+			t.Fatalf("%q isn't in the report:\n%s", line, report)
+		}
+	}
+	if code, _, _ = runWith("x", "inspect"); code != exitError {
+		t.Fatalf("expected exit %d, got %d", exitError, code)
+	}
+}
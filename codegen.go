@@ -0,0 +1,260 @@
+package gjsonpack
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Packer 由 gjsonpack-gen 生成 PackGJSON 方法的结构体, Pack 时不再使用反射
+type Packer interface {
+	PackGJSON(e *Encoder) error
+}
+
+// Unpacker 由 gjsonpack-gen 生成 UnpackGJSON 方法的结构体, Unpack 时不再经过 JSON 转换,
+// value 是解压结果: map[string]interface{}、[]interface{}、string、int64、float64、bool 或 nil
+type Unpacker interface {
+	UnpackGJSON(value interface{}) error
+}
+
+// Encoder 生成的 PackGJSON 方法使用的语法树生成器, 与反射的压缩顺序一致
+type Encoder struct {
+	state *packState
+	// The objects and arrays being built
+	frames [][]interface{}
+	// The last key of the current object
+	key    string
+	result ast
+	// The first error of a method without an error result
+	err error
+}
+
+// add 将 node 加入当前的对象或数组
+func (e *Encoder) add(node ast) {
+	if len(e.frames) == 0 {
+		e.result = node
+		return
+	}
+	e.frames[len(e.frames)-1] = append(e.frames[len(e.frames)-1], node)
+}
+
+// BeginObject 开始一个对象
+func (e *Encoder) BeginObject() {
+	e.state.enterContainer()
+	e.frames = append(e.frames, []interface{}{"$"})
+}
+
+// BeginArray 开始一个数组
+func (e *Encoder) BeginArray() {
+	e.state.enterContainer()
+	e.frames = append(e.frames, []interface{}{"@"})
+}
+
+// End 结束当前的对象或数组
+func (e *Encoder) End() {
+	frame := e.frames[len(e.frames)-1]
+	e.frames = e.frames[:len(e.frames)-1]
+	e.state.leaveContainer(frame)
+	e.add(frame)
+}
+
+// Key 写入对象的键
+func (e *Encoder) Key(key string) {
+	e.key = key
+	e.String(key)
+}
+
+// String 写入字符串
+func (e *Encoder) String(value string) {
+	if len(value) <= 0 {
+		e.add(e.state.literal(astInfo{Type: "empty", Index: tokenEmptyString}))
+		return
+	}
+	e.add(assertionString(value, e.state))
+}
+
+// Int 写入整数
+func (e *Encoder) Int(value int64) {
+	e.add(assertionIntegers(value, e.state))
+}
+
+// Uint 写入无符号整数, 超过 int64 范围时 PackGJSON 返回错误
+func (e *Encoder) Uint(value uint64) {
+	if value > math.MaxInt64 {
+		if e.err == nil {
+			e.err = fmt.Errorf("Unsigned integer %d overflows int64! ", value)
+		}
+		e.Null()
+		return
+	}
+	e.add(assertionIntegers(int64(value), e.state))
+}
+
+// Float 写入浮点数, 没有小数部分且在 int64 范围内时按整数写入
+func (e *Encoder) Float(value float64) {
+	if integer, isInteger := _floatToInt64(value); isInteger {
+		e.add(assertionIntegers(integer, e.state))
+		return
+	}
+	e.add(assertionFloat(value, e.state))
+}
+
+// Bool 写入布尔值
+func (e *Encoder) Bool(value bool) {
+	if value {
+		e.add(e.state.literal(astInfo{Type: "boolean", Index: tokenTrue}))
+		return
+	}
+	e.add(e.state.literal(astInfo{Type: "boolean", Index: tokenFalse}))
+}
+
+// Null 写入 null
+func (e *Encoder) Null() {
+	e.add(e.state.literal(astInfo{Type: "null", Index: tokenNull}))
+}
+
+// Value 按反射写入任意值
+func (e *Encoder) Value(value interface{}) error {
+	if len(e.frames) > 0 {
+		frame := e.frames[len(e.frames)-1]
+		if frame[0] == "$" {
+			e.state.path = append(e.state.path, "."+e.key)
+		} else {
+			e.state.path = append(e.state.path, "["+strconv.Itoa(len(frame)-1)+"]")
+		}
+		defer func() {
+			e.state.path = e.state.path[:len(e.state.path)-1]
+		}()
+	}
+	builderAst, builderAstErr := recursiveAstBuilder(value, e.state)
+	if builderAstErr != nil {
+		return builderAstErr
+	}
+	e.add(builderAst)
+	return nil
+}
+
+// IsEmpty 生成的 PackGJSON 方法判断 omitempty 字段是否为空, 规则与 encoding/json 一致
+func IsEmpty(value interface{}) bool {
+	return _isEmptyValue(reflect.ValueOf(value))
+}
+
+// UnpackObject 将解压结果 value 作为对象, null 时返回 nil
+func UnpackObject(value interface{}, dst interface{}) (map[string]interface{}, error) {
+	if value == nil || value == Undefined {
+		return nil, nil
+	}
+	object, isObject := value.(map[string]interface{})
+	if !isObject {
+		return nil, fmt.Errorf("Cannot unpack %T into %T! ", value, dst)
+	}
+	return object, nil
+}
+
+// LookupField 查找对象中的字段, 与 encoding/json 一样找不到时忽略大小写
+func LookupField(object map[string]interface{}, name string) (interface{}, bool) {
+	if value, valueExists := object[name]; valueExists {
+		return value, true
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// UnpackValue 按反射将解压结果 value 写入 dst 指向的值, 其中的结构体优先使用 UnpackGJSON
+func UnpackValue(value interface{}, dst interface{}) error {
+	return _decodeValueWith(&valueDecoder{pointers: make(map[valueDecoderKey]reflect.Value), unpackers: true}, value, dst)
+}
+
+// UnpackString 将解压结果 value 写入 dst, null 时不修改
+func UnpackString(value interface{}, dst *string) error {
+	switch node := value.(type) {
+	case nil, UndefinedType:
+		return nil
+	case string:
+		*dst = node
+		return nil
+	}
+	return fmt.Errorf("Cannot unpack %T into string! ", value)
+}
+
+// UnpackBool 将解压结果 value 写入 dst, null 时不修改
+func UnpackBool(value interface{}, dst *bool) error {
+	switch node := value.(type) {
+	case nil, UndefinedType:
+		return nil
+	case bool:
+		*dst = node
+		return nil
+	}
+	return fmt.Errorf("Cannot unpack %T into bool! ", value)
+}
+
+// UnpackInt64 将解压结果 value 写入 dst, null 时不修改, 有小数部分或超出范围时返回 *json.UnmarshalTypeError
+func UnpackInt64(value interface{}, dst *int64) error {
+	switch node := value.(type) {
+	case nil, UndefinedType:
+		return nil
+	case int64:
+		*dst = node
+		return nil
+	case float64:
+		integer, isInteger := _floatToInt64(node)
+		if !isInteger {
+			return _numberError(node, reflect.TypeOf(*dst))
+		}
+		*dst = integer
+		return nil
+	}
+	return fmt.Errorf("Cannot unpack %T into int64! ", value)
+}
+
+// UnpackInt 将解压结果 value 写入 dst, null 时不修改, 有小数部分或超出范围时返回 *json.UnmarshalTypeError
+func UnpackInt(value interface{}, dst *int) error {
+	number := int64(*dst)
+	if err := UnpackInt64(value, &number); err != nil {
+		return err
+	}
+	if int64(int(number)) != number {
+		// int is 32 bits wide
+		return _numberError(value, reflect.TypeOf(*dst))
+	}
+	*dst = int(number)
+	return nil
+}
+
+// UnpackUint64 将解压结果 value 写入 dst, null 时不修改, 有小数部分或超出范围时返回 *json.UnmarshalTypeError
+func UnpackUint64(value interface{}, dst *uint64) error {
+	var number int64
+	if value == nil || value == Undefined {
+		return nil
+	}
+	if err := UnpackInt64(value, &number); err != nil {
+		return err
+	}
+	if number < 0 {
+		return _numberError(value, reflect.TypeOf(*dst))
+	}
+	*dst = uint64(number)
+	return nil
+}
+
+// UnpackFloat64 将解压结果 value 写入 dst, null 时不修改
+func UnpackFloat64(value interface{}, dst *float64) error {
+	switch node := value.(type) {
+	case nil, UndefinedType:
+		return nil
+	case int64:
+		*dst = float64(node)
+		return nil
+	case float64:
+		*dst = node
+		return nil
+	}
+	return fmt.Errorf("Cannot unpack %T into float64! ", value)
+}
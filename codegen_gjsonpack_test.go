@@ -0,0 +1,207 @@
+// Code generated by gjsonpack-gen. DO NOT EDIT.
+
+package gjsonpack_test
+
+import "github.com/JoiLa/gjsonpack"
+
+// PackGJSON 不使用反射压缩 genArticle
+func (v genArticle) PackGJSON(e *gjsonpack.Encoder) error {
+	e.BeginObject()
+	e.Key("title")
+	e.String(v.Title)
+	e.Key("views")
+	e.Int(int64(v.Views))
+	e.Key("rating")
+	e.Float(v.Rating)
+	e.Key("ratio")
+	e.Float(float64(v.Ratio))
+	e.Key("size")
+	e.Uint(v.Size)
+	e.Key("draft")
+	e.Bool(v.Draft)
+	e.Key("tags")
+	if err := e.Value(v.Tags); err != nil {
+		return err
+	}
+	if len(v.Meta) != 0 {
+		e.Key("meta")
+		if err := e.Value(v.Meta); err != nil {
+			return err
+		}
+	}
+	e.Key("author")
+	if err := e.Value(v.Author); err != nil {
+		return err
+	}
+	e.Key("editors")
+	if err := e.Value(v.Editors); err != nil {
+		return err
+	}
+	e.Key("extra")
+	if err := e.Value(v.Extra); err != nil {
+		return err
+	}
+	e.Key("created")
+	if err := e.Value(v.Created); err != nil {
+		return err
+	}
+	e.Key("Untagged")
+	e.Int(int64(v.Untagged))
+	if v.Summary != "" {
+		e.Key("summary")
+		e.String(v.Summary)
+	}
+	if v.Cover != nil {
+		e.Key("cover")
+		if err := e.Value(v.Cover); err != nil {
+			return err
+		}
+	}
+	if v.Level != 0 {
+		e.Key("level")
+		e.Int(int64(v.Level))
+	}
+	if len(v.Notes) != 0 {
+		e.Key("notes")
+		if err := e.Value(v.Notes); err != nil {
+			return err
+		}
+	}
+	if !gjsonpack.IsEmpty(v.Updated) {
+		e.Key("updated")
+		if err := e.Value(v.Updated); err != nil {
+			return err
+		}
+	}
+	e.End()
+	return nil
+}
+
+// UnpackGJSON 不使用反射解压 genArticle
+func (v *genArticle) UnpackGJSON(value interface{}) error {
+	object, objectErr := gjsonpack.UnpackObject(value, v)
+	if object == nil || objectErr != nil {
+		return objectErr
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "title"); itemExists {
+		if err := gjsonpack.UnpackString(item, &v.Title); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "views"); itemExists {
+		if err := gjsonpack.UnpackInt(item, &v.Views); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "rating"); itemExists {
+		if err := gjsonpack.UnpackFloat64(item, &v.Rating); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "ratio"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Ratio); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "size"); itemExists {
+		if err := gjsonpack.UnpackUint64(item, &v.Size); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "draft"); itemExists {
+		if err := gjsonpack.UnpackBool(item, &v.Draft); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "tags"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Tags); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "meta"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Meta); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "author"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Author); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "editors"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Editors); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "extra"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Extra); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "created"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Created); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "Untagged"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Untagged); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "summary"); itemExists {
+		if err := gjsonpack.UnpackString(item, &v.Summary); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "cover"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Cover); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "level"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Level); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "notes"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Notes); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "updated"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PackGJSON 不使用反射压缩 genAuthor
+func (v genAuthor) PackGJSON(e *gjsonpack.Encoder) error {
+	e.BeginObject()
+	e.Key("name")
+	e.String(v.Name)
+	e.Key("level")
+	e.Int(int64(v.Level))
+	e.End()
+	return nil
+}
+
+// UnpackGJSON 不使用反射解压 genAuthor
+func (v *genAuthor) UnpackGJSON(value interface{}) error {
+	object, objectErr := gjsonpack.UnpackObject(value, v)
+	if object == nil || objectErr != nil {
+		return objectErr
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "name"); itemExists {
+		if err := gjsonpack.UnpackString(item, &v.Name); err != nil {
+			return err
+		}
+	}
+	if item, itemExists := gjsonpack.LookupField(object, "level"); itemExists {
+		if err := gjsonpack.UnpackValue(item, &v.Level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
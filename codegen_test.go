@@ -0,0 +1,197 @@
+package gjsonpack_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+//go:generate go run ./cmd/gjsonpack-gen -type genArticle,genAuthor -output codegen_gjsonpack_test.go codegen_test.go
+
+type genLevel int
+
+type genAuthor struct {
+	Name  string   `json:"name"`
+	Level genLevel `json:"level"`
+}
+
+type genArticle struct {
+	Title    string            `json:"title"`
+	Views    int               `json:"views"`
+	Rating   float64           `json:"rating"`
+	Ratio    float32           `json:"ratio"`
+	Size     uint64            `json:"size"`
+	Draft    bool              `json:"draft"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Author   *genAuthor        `json:"author"`
+	Editors  []genAuthor       `json:"editors"`
+	Extra    interface{}       `json:"extra"`
+	Created  time.Time         `json:"created"`
+	Ignored  string            `json:"-"`
+	Untagged int8
+	private  int
+	// Empty omitempty fields are omitted
+	Summary string     `json:"summary,omitempty"`
+	Cover   *genAuthor `json:"cover,omitempty"`
+	Level   genLevel   `json:"level,omitempty"`
+	Notes   []string   `json:"notes,omitempty"`
+	Updated time.Time  `json:"updated,omitempty"`
+}
+
+// plainAuthor and plainArticle have the same fields without the generated methods
+type plainAuthor struct {
+	Name  string   `json:"name"`
+	Level genLevel `json:"level"`
+}
+
+type plainArticle struct {
+	Title    string            `json:"title"`
+	Views    int               `json:"views"`
+	Rating   float64           `json:"rating"`
+	Ratio    float32           `json:"ratio"`
+	Size     uint64            `json:"size"`
+	Draft    bool              `json:"draft"`
+	Tags     []string          `json:"tags"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Author   *plainAuthor      `json:"author"`
+	Editors  []plainAuthor     `json:"editors"`
+	Extra    interface{}       `json:"extra"`
+	Created  time.Time         `json:"created"`
+	Ignored  string            `json:"-"`
+	Untagged int8
+	private  int
+	// Empty omitempty fields are omitted
+	Summary string       `json:"summary,omitempty"`
+	Cover   *plainAuthor `json:"cover,omitempty"`
+	Level   genLevel     `json:"level,omitempty"`
+	Notes   []string     `json:"notes,omitempty"`
+	Updated time.Time    `json:"updated,omitempty"`
+}
+
+// The generated methods pack byte for byte like reflection
+func TestGeneratedPack(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	generated := genArticle{
+		Title: "hello world", Views: 42, Rating: 4.5, Ratio: 0.25, Size: 42, Draft: true,
+		Tags: []string{"go", "hello world"}, Meta: map[string]string{"lang": "go"},
+		Author:  &genAuthor{Name: "joi", Level: 3},
+		Editors: []genAuthor{{Name: "la"}, {Name: "joi", Level: 42}},
+		Extra:   []interface{}{"go", 1.5, nil}, Created: created, Ignored: "ignored", Untagged: -1,
+	}
+	plain := plainArticle{
+		Title: "hello world", Views: 42, Rating: 4.5, Ratio: 0.25, Size: 42, Draft: true,
+		Tags: []string{"go", "hello world"}, Meta: map[string]string{"lang": "go"},
+		Author:  &plainAuthor{Name: "joi", Level: 3},
+		Editors: []plainAuthor{{Name: "la"}, {Name: "joi", Level: 42}},
+		Extra:   []interface{}{"go", 1.5, nil}, Created: created, Ignored: "ignored", Untagged: -1,
+	}
+	for _, opts := range [][]gjsonpack.PackOption{nil, {gjsonpack.WithSortedKeys()}, {gjsonpack.WithGraph(), gjsonpack.WithHeader()}} {
+		generatedStr, generatedErr := gjsonpack.PackWithOptions(&generated, opts...)
+		if generatedErr != nil {
+			t.Fatal(generatedErr)
+		}
+		plainStr, plainErr := gjsonpack.PackWithOptions(&plain, opts...)
+		if plainErr != nil {
+			t.Fatal(plainErr)
+		}
+		if generatedStr != plainStr {
+			t.Fatalf("generated %s, reflection %s", generatedStr, plainStr)
+		}
+		_, generatedStats, _ := gjsonpack.PackWithStats(&generated, opts...)
+		_, plainStats, _ := gjsonpack.PackWithStats(&plain, opts...)
+		if *generatedStats != *plainStats {
+			t.Fatalf("generated stats %+v, reflection %+v", generatedStats, plainStats)
+		}
+	}
+	// Empty omitempty fields are omitted on both paths
+	generatedStr, _ := gjsonpack.Pack(genArticle{Summary: "hello", Notes: []string{}})
+	plainStr, _ := gjsonpack.Pack(plainArticle{Summary: "hello", Notes: []string{}})
+	if generatedStr != plainStr || strings.Contains(generatedStr, "notes") || !strings.Contains(generatedStr, "summary") {
+		t.Fatalf("generated %s, reflection %s", generatedStr, plainStr)
+	}
+	// Whole floats beyond int64 pack as floats on both paths
+	generated.Rating, plain.Rating = 1e20, 1e20
+	generatedStr, _ = gjsonpack.Pack(generated)
+	plainStr, _ = gjsonpack.Pack(plain)
+	if generatedStr != plainStr || !strings.Contains(generatedStr, "^100000000000000000000|") {
+		t.Fatalf("generated %s, reflection %s", generatedStr, plainStr)
+	}
+	// Unsigned integers beyond int64 are an error on both paths
+	generated.Size, plain.Size = 1<<63, 1<<63
+	if _, err := gjsonpack.Pack(generated); err == nil {
+		t.Fatal("expected an overflow of the generated size")
+	}
+	if _, err := gjsonpack.Pack(plain); err == nil {
+		t.Fatal("expected an overflow of the reflected size")
+	}
+	var _ gjsonpack.Packer = genArticle{}
+	var _ gjsonpack.Unpacker = &genArticle{}
+}
+
+// The generated methods unpack like reflection
+func TestGeneratedUnpack(t *testing.T) {
+	// time.Time packs as an empty object, which doesn't unpack by reflection either
+	packStr, packErr := gjsonpack.Pack(map[string]interface{}{
+		"title": "hello world", "views": 42, "rating": 4.5, "size": 7, "draft": true, "tags": []string{"go"},
+		"author": plainAuthor{Name: "joi", Level: 3}, "editors": []plainAuthor{{Name: "la"}},
+		"extra": map[string]interface{}{"a": true}, "untagged": -1,
+	})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	var generated genArticle
+	if err := gjsonpack.Unpack(packStr, &generated); err != nil {
+		t.Fatal(err)
+	}
+	var plain plainArticle
+	if err := gjsonpack.Unpack(packStr, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if generated.Title != plain.Title || generated.Views != plain.Views || generated.Rating != plain.Rating ||
+		generated.Size != plain.Size || generated.Draft != plain.Draft || !reflect.DeepEqual(generated.Tags, plain.Tags) ||
+		*generated.Author != genAuthor(*plain.Author) || generated.Editors[0] != genAuthor(plain.Editors[0]) ||
+		!reflect.DeepEqual(generated.Extra, plain.Extra) || generated.Untagged != -1 {
+		t.Fatalf("generated %+v, reflection %+v", generated, plain)
+	}
+	// A value of the wrong type is an error
+	badStr, _ := gjsonpack.Pack(map[string]interface{}{"title": 1})
+	if err := gjsonpack.Unpack(badStr, &generated); err == nil {
+		t.Fatal("expected an error for a number title")
+	}
+}
+
+// Numbers that don't fit are errors like in encoding/json
+func TestUnpackNumbers(t *testing.T) {
+	integer := 7
+	var integer64 int64
+	size := uint64(1 << 63)
+	if err := gjsonpack.UnpackInt(nil, &integer); err != nil || integer != 7 {
+		t.Fatalf("null modified the integer %d %v", integer, err)
+	}
+	if err := gjsonpack.UnpackUint64(nil, &size); err != nil || size != 1<<63 {
+		t.Fatalf("null modified the size %d %v", size, err)
+	}
+	if err := gjsonpack.UnpackInt64(float64(42), &integer64); err != nil || integer64 != 42 {
+		t.Fatalf("unexpected integer %d %v", integer64, err)
+	}
+	for _, value := range []interface{}{1.5, 1e19, -1e19} {
+		if err := gjsonpack.UnpackInt64(value, &integer64); err == nil {
+			t.Fatalf("expected an error for %v", value)
+		}
+		if err := gjsonpack.UnpackInt(value, &integer); err == nil {
+			t.Fatalf("expected an error for %v", value)
+		}
+	}
+	for _, value := range []interface{}{int64(-1), -1.0, 0.5} {
+		if err := gjsonpack.UnpackUint64(value, &size); err == nil {
+			t.Fatalf("expected an error for %v", value)
+		}
+	}
+	if integer64 != 42 || integer != 7 || size != 1<<63 {
+		t.Fatalf("bad numbers modified %d %d %d", integer64, integer, size)
+	}
+}
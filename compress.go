@@ -0,0 +1,128 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Compression 在字典压缩之后叠加的第二阶段压缩
+type Compression int
+
+const (
+	// CompressionNone 不叠加压缩
+	CompressionNone Compression = iota
+	// CompressionFlate 叠加 DEFLATE 压缩
+	CompressionFlate
+	// CompressionGzip 叠加 gzip 压缩
+	CompressionGzip
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionFlate:
+		return "flate"
+	case CompressionGzip:
+		return "gzip"
+	}
+	return fmt.Sprintf("Compression(%d)", int(c))
+}
+
+// feature 压缩方式在版本头中对应的特性
+func (c Compression) feature() Feature {
+	switch c {
+	case CompressionFlate:
+		return FeatureFlate
+	case CompressionGzip:
+		return FeatureGzip
+	}
+	return 0
+}
+
+// _compress 压缩 packed, 结果以 URL 安全的 base64 表示
+func _compress(packed string, compression Compression) (string, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var writerErr error
+	switch compression {
+	case CompressionFlate:
+		writer, writerErr = flate.NewWriter(&buffer, flate.BestCompression)
+	case CompressionGzip:
+		writer, writerErr = gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	default:
+		return "", fmt.Errorf("Unexpected compression %s! ", compression)
+	}
+	if writerErr != nil {
+		return "", writerErr
+	}
+	if _, err := io.WriteString(writer, packed); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// _decompress 按版本头中的特性解压 packed, maxSize 限制解压后的字节数
+func _decompress(packed string, features Feature, maxSize int64) (string, error) {
+	compressed, decodeErr := base64.RawURLEncoding.DecodeString(packed)
+	if decodeErr != nil {
+		return "", decodeErr
+	}
+	var reader io.Reader
+	switch {
+	case features&FeatureFlate != 0:
+		flateReader := flate.NewReader(bytes.NewReader(compressed))
+		defer flateReader.Close()
+		reader = flateReader
+	case features&FeatureGzip != 0:
+		gzipReader, gzipReaderErr := gzip.NewReader(bytes.NewReader(compressed))
+		if gzipReaderErr != nil {
+			return "", gzipReaderErr
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	default:
+		return packed, nil
+	}
+	if maxSize > 0 {
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+	decompressed, readErr := io.ReadAll(reader)
+	if readErr != nil {
+		return "", readErr
+	}
+	if err := _checkLimit("MaxInputSize", maxSize, int64(len(decompressed))); err != nil {
+		return "", err
+	}
+	return string(decompressed), nil
+}
+
+// PackSmallest 分别以不压缩、DEFLATE、gzip 压缩 json 参数中的数据,
+// 返回最短的结果及其压缩方式, opts 中的压缩方式会被忽略
+func PackSmallest(json interface{}, opts ...PackOption) (string, Compression, error) {
+	options := NewPackOptions(opts...)
+	packedBody, features, packedBodyErr := _packBody(json, &options)
+	if packedBodyErr != nil {
+		return "", CompressionNone, packedBodyErr
+	}
+	var smallest string
+	var smallestCompression Compression
+	for i, compression := range []Compression{CompressionNone, CompressionFlate, CompressionGzip} {
+		options.Compression = compression
+		packed, packedErr := _wrapPacked(packedBody, packedHeader{Features: features}, &options)
+		if packedErr != nil {
+			return "", CompressionNone, packedErr
+		}
+		if i == 0 || len(packed) < len(smallest) {
+			smallest, smallestCompression = packed, compression
+		}
+	}
+	return smallest, smallestCompression, nil
+}
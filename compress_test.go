@@ -0,0 +1,56 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Compression with a second DEFLATE or gzip stage
+func TestPackCompression(t *testing.T) {
+	jsonSlice := make([]interface{}, 0)
+	for i := 0; i < 50; i++ {
+		jsonMap := make(map[string]interface{}, 0)
+		if err := json.Unmarshal([]byte(basicJSON), &jsonMap); err != nil {
+			t.Fatal(err)
+		}
+		jsonSlice = append(jsonSlice, jsonMap)
+	}
+	plainStr, plainErr := PackWithOptions(jsonSlice, WithSortedKeys())
+	if plainErr != nil {
+		t.Fatal(plainErr)
+	}
+	plainJSON, _ := UnpackToStr(plainStr)
+	for _, compression := range []Compression{CompressionFlate, CompressionGzip} {
+		packStr, packErr := PackWithOptions(jsonSlice, WithSortedKeys(), WithCompression(compression), WithChecksum())
+		if packErr != nil {
+			t.Fatal(packErr)
+		}
+		if len(packStr) >= len(plainStr) {
+			t.Fatalf("%s: %d isn't smaller than %d", compression, len(packStr), len(plainStr))
+		}
+		jsonStr, unPackErr := UnpackToStr(packStr)
+		if unPackErr != nil {
+			t.Fatal(unPackErr)
+		}
+		if jsonStr != plainJSON {
+			t.Fatalf("%s: unexpected json %s", compression, jsonStr)
+		}
+		// The decompressed data is limited as well
+		if _, unPackErr = NewUnpackOptions(WithMaxInputSize(int64(len(packStr)))).UnpackToStr(packStr); unPackErr == nil {
+			t.Fatalf("%s: expected a limit error", compression)
+		}
+	}
+	smallestStr, compression, smallestErr := PackSmallest(jsonSlice, WithSortedKeys())
+	if smallestErr != nil {
+		t.Fatal(smallestErr)
+	}
+	if compression == CompressionNone || !strings.HasPrefix(smallestStr, "%GJP1.") {
+		t.Fatalf("unexpected smallest %s %s", compression, smallestStr)
+	}
+	// Small data is smallest without compression
+	smallestStr, compression, _ = PackSmallest(map[string]interface{}{"a": 1})
+	if compression != CompressionNone || smallestStr != "a^1^^$0|1]" {
+		t.Fatalf("unexpected smallest %s %s", compression, smallestStr)
+	}
+}
@@ -0,0 +1,330 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+var numberType = reflect.TypeOf(json.Number(""))
+
+// structField 结构体中参与压缩的字段
+type structField struct {
+	// Name 字段在 JSON 中的名称
+	Name string
+	// Index 字段在结构体中的位置
+	Index int
+	// OmitEmpty 标签带有 omitempty, 空值不参与压缩
+	OmitEmpty bool
+}
+
+// _structFields 按 json 标签获取结构体中参与压缩的字段
+func _structFields(t reflect.Type) []structField {
+	fieldLen := t.NumField()
+	fields := make([]structField, 0, fieldLen)
+	for i := 0; i < fieldLen; i++ {
+		objField := t.Field(i)
+		if objField.PkgPath != "" {
+			// The field is unexported
+			continue
+		}
+		structJsonTagName := objField.Tag.Get("json")
+		if structJsonTagName == "-" {
+			continue
+		}
+		var omitEmpty bool
+		if comma := strings.Index(structJsonTagName, ","); comma >= 0 {
+			for _, option := range strings.Split(structJsonTagName[comma+1:], ",") {
+				omitEmpty = omitEmpty || option == "omitempty"
+			}
+			structJsonTagName = structJsonTagName[:comma]
+		}
+		if structJsonTagName == "" {
+			structJsonTagName = objField.Name
+		}
+		fields = append(fields, structField{Name: structJsonTagName, Index: i, OmitEmpty: omitEmpty})
+	}
+	return fields
+}
+
+// _isEmptyValue 与 encoding/json 的 omitempty 一致, false、0、nil 以及长度为 0 的值为空
+func _isEmptyValue(refValue reflect.Value) bool {
+	switch refValue.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return refValue.Len() == 0
+	case reflect.Bool:
+		return !refValue.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return refValue.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return refValue.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return refValue.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return refValue.IsNil()
+	}
+	return false
+}
+
+// valueDecoder 将解压结果写入 Go 值, 同一个共享对象只会创建一个指针
+type valueDecoder struct {
+	pointers map[valueDecoderKey]reflect.Value
+	// unpackers decodes with the generated UnpackGJSON methods
+	unpackers bool
+	// useNumber decodes the numbers in interface{} as json.Number like UnpackOptions.UseNumber
+	useNumber bool
+	// The copies of the objects with json.Number, shared objects stay shared
+	numberObjects map[uintptr]map[string]interface{}
+}
+
+// valueDecoderKey 标识解压结果中的一个对象及其目标类型
+type valueDecoderKey struct {
+	node uintptr
+	typ  reflect.Type
+}
+
+// _decodeValue 将解压结果 node 写入 v 指向的值
+func _decodeValue(node interface{}, v interface{}) error {
+	return _decodeValueWith(&valueDecoder{pointers: make(map[valueDecoderKey]reflect.Value)}, node, v)
+}
+
+// _decodeValueWith 使用 decoder 将解压结果 node 写入 v 指向的值
+func _decodeValueWith(decoder *valueDecoder, node interface{}, v interface{}) error {
+	refValue := reflect.ValueOf(v)
+	if refValue.Kind() != reflect.Ptr || refValue.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	return decoder.decode(node, refValue.Elem())
+}
+
+// decode 将 node 写入 dst
+func (d *valueDecoder) decode(node interface{}, dst reflect.Value) error {
+	if d.unpackers && dst.CanAddr() && dst.Kind() == reflect.Struct {
+		if unpacker, ok := dst.Addr().Interface().(Unpacker); ok {
+			return unpacker.UnpackGJSON(node)
+		}
+	}
+	if dst.CanAddr() {
+		if unmarshaler, ok := dst.Addr().Interface().(json.Unmarshaler); ok {
+			jsonBytes, jsonMarshalErr := json.Marshal(node)
+			if jsonMarshalErr != nil {
+				return jsonMarshalErr
+			}
+			return unmarshaler.UnmarshalJSON(jsonBytes)
+		}
+	}
+	if node == Undefined && dst.Kind() != reflect.Interface {
+		// Undefined is null outside of interface{}
+		node = nil
+	}
+	if node == nil {
+		switch dst.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			break
+		}
+		if d.useNumber {
+			node = d.numbers(node)
+		}
+		dst.Set(reflect.ValueOf(node))
+		return nil
+	case reflect.Ptr:
+		objectNode, isObject := node.(map[string]interface{})
+		if !isObject {
+			refPointer := reflect.New(dst.Type().Elem())
+			if err := d.decode(node, refPointer.Elem()); err != nil {
+				return err
+			}
+			dst.Set(refPointer)
+			return nil
+		}
+		// Objects shared in graph mode are decoded into the same pointer
+		key := valueDecoderKey{node: reflect.ValueOf(objectNode).Pointer(), typ: dst.Type()}
+		if refPointer, refPointerExists := d.pointers[key]; refPointerExists {
+			dst.Set(refPointer)
+			return nil
+		}
+		refPointer := reflect.New(dst.Type().Elem())
+		d.pointers[key] = refPointer
+		dst.Set(refPointer)
+		return d.decode(node, refPointer.Elem())
+	case reflect.Struct:
+		objectNode, isObject := node.(map[string]interface{})
+		if !isObject {
+			break
+		}
+		for _, field := range _structFields(dst.Type()) {
+			fieldNode, fieldNodeExists := objectNode[field.Name]
+			if !fieldNodeExists {
+				// Like encoding/json, fall back to a case-insensitive match
+				for nodeKey, nodeValue := range objectNode {
+					if strings.EqualFold(nodeKey, field.Name) {
+						fieldNode, fieldNodeExists = nodeValue, true
+						break
+					}
+				}
+			}
+			if !fieldNodeExists {
+				continue
+			}
+			if err := d.decode(fieldNode, dst.Field(field.Index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		objectNode, isObject := node.(map[string]interface{})
+		if !isObject {
+			break
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(objectNode)))
+		}
+		for nodeKey, nodeValue := range objectNode {
+			refElem := reflect.New(dst.Type().Elem()).Elem()
+			if err := d.decode(nodeValue, refElem); err != nil {
+				return err
+			}
+			refKey, refKeyErr := _parseMapKey(nodeKey, dst.Type().Key())
+			if refKeyErr != nil {
+				return refKeyErr
+			}
+			dst.SetMapIndex(refKey, refElem)
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		arrayNode, isArray := node.([]interface{})
+		if !isArray {
+			break
+		}
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(arrayNode), len(arrayNode)))
+		}
+		for i := 0; i < len(arrayNode) && i < dst.Len(); i++ {
+			if err := d.decode(arrayNode[i], dst.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		if stringNode, isString := node.(string); isString {
+			dst.SetString(stringNode)
+			return nil
+		}
+		if dst.Type() == numberType {
+			switch node.(type) {
+			case int64, float64:
+				dst.Set(reflect.ValueOf(_jsonNumber(node)))
+				return nil
+			}
+		}
+	case reflect.Bool:
+		if boolNode, isBool := node.(bool); isBool {
+			dst.SetBool(boolNode)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch numberNode := node.(type) {
+		case int64:
+			if dst.OverflowInt(numberNode) {
+				return _numberError(numberNode, dst.Type())
+			}
+			dst.SetInt(numberNode)
+			return nil
+		case float64:
+			integer, isInteger := _floatToInt64(numberNode)
+			if !isInteger || dst.OverflowInt(integer) {
+				return _numberError(numberNode, dst.Type())
+			}
+			dst.SetInt(integer)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch numberNode := node.(type) {
+		case int64:
+			if numberNode < 0 || dst.OverflowUint(uint64(numberNode)) {
+				return _numberError(numberNode, dst.Type())
+			}
+			dst.SetUint(uint64(numberNode))
+			return nil
+		case float64:
+			integer, isInteger := _floatToInt64(numberNode)
+			if !isInteger || integer < 0 || dst.OverflowUint(uint64(integer)) {
+				return _numberError(numberNode, dst.Type())
+			}
+			dst.SetUint(uint64(integer))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch numberNode := node.(type) {
+		case int64:
+			dst.SetFloat(float64(numberNode))
+			return nil
+		case float64:
+			if dst.OverflowFloat(numberNode) {
+				return _numberError(numberNode, dst.Type())
+			}
+			dst.SetFloat(numberNode)
+			return nil
+		}
+	}
+	return fmt.Errorf("Cannot unpack %T into %s! ", node, dst.Type())
+}
+
+// numbers 将 node 中的数字转换为 json.Number, 对象会被复制
+func (d *valueDecoder) numbers(node interface{}) interface{} {
+	switch typedNode := node.(type) {
+	case int64, float64:
+		return _jsonNumber(typedNode)
+	case map[string]interface{}:
+		key := reflect.ValueOf(typedNode).Pointer()
+		if copied, copiedExists := d.numberObjects[key]; copiedExists {
+			return copied
+		}
+		if d.numberObjects == nil {
+			d.numberObjects = make(map[uintptr]map[string]interface{})
+		}
+		copied := make(map[string]interface{}, len(typedNode))
+		// Register the copy first, graph mode may have cycles
+		d.numberObjects[key] = copied
+		for nodeKey, nodeValue := range typedNode {
+			copied[nodeKey] = d.numbers(nodeValue)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typedNode))
+		for i, item := range typedNode {
+			copied[i] = d.numbers(item)
+		}
+		return copied
+	}
+	return node
+}
+
+// _jsonNumber 数字的 json.Number, 与 JSON 中的写法一致
+func _jsonNumber(number interface{}) json.Number {
+	jsonBytes, _ := json.Marshal(number)
+	return json.Number(jsonBytes)
+}
+
+// _floatToInt64 浮点数没有小数部分且在 int64 范围内时转换为整数
+func _floatToInt64(number float64) (int64, bool) {
+	// -2^63 is exact in float64, 2^63 is the first value out of range
+	if number != math.Trunc(number) || number < math.MinInt64 || number >= -math.MinInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
+
+// _numberError 与 encoding/json 一样, 数字不能写入 t 时返回 *json.UnmarshalTypeError
+func _numberError(number interface{}, t reflect.Type) error {
+	return &json.UnmarshalTypeError{Value: fmt.Sprintf("number %v", number), Type: t}
+}
@@ -0,0 +1,190 @@
+package gjsonpack
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Encoding 压缩结果的输出字母表, 将标准格式中的字符替换为其他字符
+type Encoding struct {
+	// The escape character
+	escape byte
+	// The replacement of every byte of the standard format
+	encode [256]string
+	// The standard byte of a replacing character, -1 when it is kept as it is
+	decode [256]int
+	// The standard byte of a code after the escape character, -1 when it isn't a code
+	codes [256]int
+}
+
+// URLEncoding 只使用 A-Z a-z 0-9 - . _ ~ 的输出字母表,
+// 可以直接用于查询参数、Cookie 与 HTTP 头而不需要再转义
+var URLEncoding = _newEncoding('_',
+	map[byte]byte{'|': '.', ']': '~'},
+	map[byte]byte{'^': 's', '$': 'o', '@': 'a', '+': 'p', '&': 'r', '~': 't', '%': 'e', '.': 'd', '_': 'u'},
+	func(c byte) bool {
+		return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-'
+	},
+)
+
+// Alphabet 自定义标准格式中的分隔符与转义字符, 零值字段使用标准格式的字符
+type Alphabet struct {
+	// Section 分隔字典与结构区, 标准格式为 '^'
+	Section byte
+	// Item 分隔字典条目与结构区的 token, 标准格式为 '|'
+	Item byte
+	// Object 对象的开始, 标准格式为 '$'
+	Object byte
+	// Array 数组的开始, 标准格式为 '@'
+	Array byte
+	// End 对象与数组的结束, 标准格式为 ']'
+	End byte
+	// Escape 转义字符, 与分隔符冲突的字符转义为 Escape 加两位十六进制, 默认为 '!'
+	Escape byte
+	// Reserved 其他需要转义的字符, 如嵌入 CSV 时的 ',' '"' 与换行, 不能包含转义使用的 0-9 A-F
+	Reserved string
+}
+
+// NewEncoding 由 a 创建输出字母表
+func NewEncoding(a Alphabet) (*Encoding, error) {
+	var standards = []byte{'^', '|', '$', '@', ']'}
+	var chars = []*byte{&a.Section, &a.Item, &a.Object, &a.Array, &a.End, &a.Escape}
+	var defaults = []byte{'^', '|', '$', '@', ']', '!'}
+	var used = make(map[byte]bool)
+	for i, c := range chars {
+		if *c == 0 {
+			*c = defaults[i]
+		}
+		if *c <= ' ' || *c > '~' || *c >= '0' && *c <= '9' || *c >= 'A' && *c <= 'Z' || *c >= 'a' && *c <= 'z' {
+			return nil, fmt.Errorf("Bad alphabet, %q isn't a printable symbol! ", *c)
+		}
+		if used[*c] {
+			return nil, fmt.Errorf("Bad alphabet, %q is used twice! ", *c)
+		}
+		used[*c] = true
+	}
+	if i := strings.IndexAny(a.Reserved, "0123456789ABCDEF"); i >= 0 {
+		return nil, fmt.Errorf("Bad alphabet, the hex digit %q of escapes can't be reserved! ", a.Reserved[i])
+	}
+	if strings.IndexByte(a.Reserved, a.Escape) >= 0 {
+		return nil, fmt.Errorf("Bad alphabet, the escape %q is reserved! ", a.Escape)
+	}
+	var replaces = make(map[byte]byte)
+	for i, c := range chars[:5] {
+		if strings.IndexByte(a.Reserved, *c) >= 0 {
+			return nil, fmt.Errorf("Bad alphabet, %q is reserved! ", *c)
+		}
+		replaces[standards[i]] = *c
+	}
+	return _newEncoding(a.Escape, replaces, nil, func(c byte) bool {
+		return !used[c] && strings.IndexByte(a.Reserved, c) < 0
+	}), nil
+}
+
+// _newEncoding 创建输出字母表, replaces 中的字符替换为单个字符, codes 中的字符替换为转义字符加代码,
+// 其余 safe 的字符保持不变, 不 safe 的字符替换为转义字符加两位十六进制
+func _newEncoding(escape byte, replaces, codes map[byte]byte, safe func(c byte) bool) *Encoding {
+	e := &Encoding{escape: escape}
+	for i := 0; i < 256; i++ {
+		e.decode[i] = -1
+		e.codes[i] = -1
+	}
+	for i := 0; i < 256; i++ {
+		c := byte(i)
+		if replace, ok := replaces[c]; ok {
+			e.encode[i] = string(replace)
+			e.decode[replace] = i
+		} else if code, ok := codes[c]; ok {
+			e.encode[i] = string([]byte{escape, code})
+			e.codes[code] = i
+		} else if safe(c) && c != escape {
+			e.encode[i] = string(c)
+		} else {
+			e.encode[i] = fmt.Sprintf("%c%02X", escape, c)
+		}
+	}
+	return e
+}
+
+// Encode 将标准格式的 packed 转换为该字母表
+func (e *Encoding) Encode(packed string) string {
+	encoded := make([]byte, 0, len(packed))
+	for i := 0; i < len(packed); i++ {
+		encoded = append(encoded, e.encode[packed[i]]...)
+	}
+	return string(encoded)
+}
+
+// Decode 将该字母表的 encoded 转换回标准格式
+func (e *Encoding) Decode(encoded string) (string, error) {
+	decoded := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		c := encoded[i]
+		if c == e.escape {
+			if i+1 < len(encoded) && e.codes[encoded[i+1]] >= 0 {
+				decoded = append(decoded, byte(e.codes[encoded[i+1]]))
+				i++
+				continue
+			}
+			if i+2 < len(encoded) {
+				if high, low := _unhex(encoded[i+1]), _unhex(encoded[i+2]); high >= 0 && low >= 0 {
+					decoded = append(decoded, byte(high<<4|low))
+					i += 2
+					continue
+				}
+			}
+			return "", fmt.Errorf("Bad escape at %d of the encoded data! ", i)
+		}
+		if e.decode[c] >= 0 {
+			decoded = append(decoded, byte(e.decode[c]))
+			continue
+		}
+		if e.encode[c] != string(c) {
+			return "", fmt.Errorf("Bad character %q at %d of the encoded data! ", c, i)
+		}
+		decoded = append(decoded, c)
+	}
+	return string(decoded), nil
+}
+
+// _unhex 十六进制字符转数值, 不是大写十六进制字符时返回 -1
+func _unhex(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// SetQuery 以 URLEncoding 压缩 json 参数中的数据并写入 values 的 key
+func SetQuery(values url.Values, key string, json interface{}, opts ...PackOption) error {
+	packed, packedErr := PackWithOptions(json, append(opts, WithEncoding(URLEncoding))...)
+	if packedErr != nil {
+		return packedErr
+	}
+	values.Set(key, packed)
+	return nil
+}
+
+// GetQuery 以 URLEncoding 解压 values 中 key 的数据
+func GetQuery(values url.Values, key string, v interface{}, opts ...UnpackOption) error {
+	return UnpackWithOptions(values.Get(key), v, append(opts, WithDecoding(URLEncoding))...)
+}
+
+// NewCookie 以 URLEncoding 压缩 json 参数中的数据并创建名为 name 的 Cookie
+func NewCookie(name string, json interface{}, opts ...PackOption) (*http.Cookie, error) {
+	packed, packedErr := PackWithOptions(json, append(opts, WithEncoding(URLEncoding))...)
+	if packedErr != nil {
+		return nil, packedErr
+	}
+	return &http.Cookie{Name: name, Value: packed}, nil
+}
+
+// UnpackCookie 以 URLEncoding 解压 cookie 中的数据
+func UnpackCookie(cookie *http.Cookie, v interface{}, opts ...UnpackOption) error {
+	return UnpackWithOptions(cookie.Value, v, append(opts, WithDecoding(URLEncoding))...)
+}
@@ -0,0 +1,128 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Compression to the URL-safe alphabet
+func TestURLEncoding(t *testing.T) {
+	jsonMap := map[string]interface{}{
+		"text":  "a b+c|d^e%f_g.h~i",
+		"emoji": "café ☕",
+		"pi":    3.14,
+		"list":  []interface{}{-1, true, nil, ""},
+	}
+	for _, opts := range [][]PackOption{
+		{WithSortedKeys()},
+		{WithSortedKeys(), WithChecksum()},
+		{WithSortedKeys(), WithCompression(CompressionGzip)},
+	} {
+		packStr, packErr := PackWithOptions(jsonMap, append(opts, WithEncoding(URLEncoding))...)
+		if packErr != nil {
+			t.Fatal(packErr)
+		}
+		if url.QueryEscape(packStr) != packStr {
+			t.Fatalf("%s isn't URL-safe", packStr)
+		}
+		unpacked := make(map[string]interface{}, 0)
+		if err := UnpackWithOptions(packStr, &unpacked, WithDecoding(URLEncoding)); err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := json.Marshal(jsonMap)
+		actual, _ := json.Marshal(unpacked)
+		if string(expected) != string(actual) {
+			t.Fatalf("unexpected json %s", actual)
+		}
+	}
+	if _, err := URLEncoding.Decode("type|world"); err == nil {
+		t.Fatal("expected a bad character")
+	}
+	if _, err := URLEncoding.Decode("type_Z"); err == nil {
+		t.Fatal("expected a bad escape")
+	}
+}
+
+// Compression into query strings and cookies
+func TestQueryAndCookie(t *testing.T) {
+	jsonMap := map[string]interface{}{"type": "continent", "name": "South America"}
+	values := url.Values{}
+	if err := SetQuery(values, "q", jsonMap); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(values.Encode(), "%") {
+		t.Fatalf("the query %s is escaped", values.Encode())
+	}
+	parsed, _ := url.ParseQuery(values.Encode())
+	fromQuery := make(map[string]interface{}, 0)
+	if err := GetQuery(parsed, "q", &fromQuery); err != nil {
+		t.Fatal(err)
+	}
+	if fromQuery["name"] != "South America" {
+		t.Fatalf("unexpected query value %v", fromQuery)
+	}
+	cookie, cookieErr := NewCookie("state", jsonMap)
+	if cookieErr != nil {
+		t.Fatal(cookieErr)
+	}
+	recorder := httptest.NewRecorder()
+	recorder.Header().Add("Set-Cookie", cookie.String())
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != cookie.Value {
+		t.Fatalf("the cookie %s isn't kept as it is", cookie.String())
+	}
+	fromCookie := make(map[string]interface{}, 0)
+	if err := UnpackCookie(cookies[0], &fromCookie); err != nil {
+		t.Fatal(err)
+	}
+	if fromCookie["type"] != "continent" {
+		t.Fatalf("unexpected cookie value %v", fromCookie)
+	}
+}
+
+// Compression to a custom alphabet
+func TestAlphabet(t *testing.T) {
+	csvEncoding, encodingErr := NewEncoding(Alphabet{Section: ';', Item: ':', Object: '{', Array: '[', End: '}', Escape: '\\', Reserved: ",\"\n"})
+	if encodingErr != nil {
+		t.Fatal(encodingErr)
+	}
+	jsonMap := map[string]interface{}{
+		"csv":  "a,b;c:d\"e\nf\\g",
+		"list": []interface{}{1, 2.5, "{[}]"},
+	}
+	packStr, packErr := PackWithOptions(jsonMap, WithSortedKeys(), WithEncoding(csvEncoding))
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	expected := `csv:a\2Cb\3Bc\3Ad\22e\0Af\5Cg:list:\7B\5B\7D};1;2.5;{0:1:2:[4:5:3}}`
+	if packStr != expected {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	unpacked := make(map[string]interface{}, 0)
+	if err := UnpackWithOptions(packStr, &unpacked, WithDecoding(csvEncoding)); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked["csv"] != jsonMap["csv"] {
+		t.Fatalf("unexpected unpacked %v", unpacked)
+	}
+	// Swapped delimiters are also reversible
+	swapped, _ := NewEncoding(Alphabet{Section: '|', Item: '^'})
+	packStr, _ = PackWithOptions(jsonMap, WithSortedKeys(), WithEncoding(swapped))
+	if err := UnpackWithOptions(packStr, &unpacked, WithDecoding(swapped)); err != nil {
+		t.Fatal(err)
+	}
+	// Reserved characters never appear in the output
+	reserved, _ := NewEncoding(Alphabet{Reserved: ",x"})
+	packStr, _ = PackWithOptions(map[string]interface{}{"a": "x|y", "b": 7}, WithEncoding(reserved))
+	if strings.ContainsAny(packStr, ",x") {
+		t.Fatalf("unexpected reserved byte in %s", packStr)
+	}
+	for _, alphabet := range []Alphabet{{Item: 'x'}, {Item: '^'}, {Escape: ' '}, {Escape: '!', Reserved: "!"}, {End: ',', Reserved: ","}, {Reserved: "7,"}, {Reserved: "F"}} {
+		if _, err := NewEncoding(alphabet); err == nil {
+			t.Fatalf("%+v: expected a bad alphabet", alphabet)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Explain 将 packed 的结构区展开为缩进的树, 每个 token 都解析为字典中的值并带有字节偏移, 如
+//
+//	    17  $ object
+//	    18    0 -> "type":
+//	    20      1 -> "world"
+//
+// 偏移以标准字母表计算, 数据经过 DEFLATE 或 gzip 压缩时为解压后的偏移
+func Explain(packed string, opts ...UnpackOption) (string, error) {
+	options := NewUnpackOptions(opts...)
+	state, stateErr := _unpackState(packed, &options)
+	if stateErr != nil {
+		return "", stateErr
+	}
+	x := &explainer{state: state}
+	if state.header.Version > 0 {
+		fmt.Fprintf(&x.out, "# version %d, features %#x\n", state.header.Version, int64(state.header.Features))
+	}
+	var first int64
+	for section, name := range []string{"strings", "integers", "floats"} {
+		var entries int64
+		if state.sections[section] != "" {
+			entries = int64(strings.Count(state.sections[section], "|")) + 1
+		}
+		if entries > 0 {
+			fmt.Fprintf(&x.out, "# %s %s-%s\n", name, _baseInt10To36(first), _baseInt10To36(first+entries-1))
+		} else {
+			fmt.Fprintf(&x.out, "# %s none\n", name)
+		}
+		first += entries
+	}
+	if err := x.value(0); err != nil {
+		return "", err
+	}
+	return x.out.String(), nil
+}
+
+// explainer 展开结构区的状态
+type explainer struct {
+	state *unpackState
+	out   bytes.Buffer
+	// The index of the next token
+	index int64
+	// The number of anchors so far
+	anchors int64
+}
+
+// next 读取下一个 token 及其偏移
+func (x *explainer) next() (interface{}, int64, error) {
+	if x.index >= int64(len(x.state.tokenSlice)) {
+		return nil, 0, errors.New("Unexpected end of the structure! ")
+	}
+	x.index++
+	return x.state.tokenSlice[x.index-1], x.state.tokenOffsets[x.index-1], nil
+}
+
+// line 输出一行
+func (x *explainer) line(offset int64, depth int, text string) {
+	fmt.Fprintf(&x.out, "%6d  %s%s\n", offset, strings.Repeat("  ", depth), text)
+}
+
+// resolve 将数值 token 解析为可读的值
+func (x *explainer) resolve(token int64) string {
+	var value string
+	switch token {
+	case tokenTrue:
+		value = "true"
+	case tokenFalse:
+		value = "false"
+	case tokenNull:
+		value = "null"
+	case tokenEmptyString:
+		value = `""`
+	case tokenUndefined:
+		value = "undefined"
+	default:
+		if token < 0 || token >= int64(len(x.state.dictionarySlice)) {
+			value = "?"
+			break
+		}
+		jsonBytes, _ := json.Marshal(x.state.dictionarySlice[token])
+		value = string(jsonBytes)
+	}
+	return _baseInt10To36(token) + " -> " + value
+}
+
+// value 展开下一个值
+func (x *explainer) value(depth int) error {
+	token, offset, tokenErr := x.next()
+	if tokenErr != nil {
+		return tokenErr
+	}
+	switch token {
+	case "&":
+		symbol, _, symbolErr := x.next()
+		if symbolErr != nil {
+			return symbolErr
+		}
+		x.line(offset, depth, fmt.Sprintf("&%v %s, anchor %d", symbol, _explainSymbol(symbol), x.anchors))
+		x.anchors++
+		return x.children(symbol, depth)
+	case "$", "@":
+		x.line(offset, depth, fmt.Sprintf("%v %s", token, _explainSymbol(token)))
+		return x.children(token, depth)
+	case "~":
+		anchor, _, anchorErr := x.next()
+		if anchorErr != nil {
+			return anchorErr
+		}
+		x.line(offset, depth, fmt.Sprintf("~%s -> anchor %v", _baseInt10To36(anchor.(int64)), anchor))
+		return nil
+	}
+	number, isNumber := token.(int64)
+	if !isNumber {
+		return fmt.Errorf("Bad token %v isn't a value! ", token)
+	}
+	x.line(offset, depth, x.resolve(number))
+	return nil
+}
+
+// children 展开对象或数组的成员直到 ']'
+func (x *explainer) children(symbol interface{}, depth int) error {
+	for {
+		if x.index >= int64(len(x.state.tokenSlice)) {
+			return errors.New("Unexpected end of the structure! ")
+		}
+		if x.state.tokenSlice[x.index] == "]" {
+			_, offset, _ := x.next()
+			x.line(offset, depth, "] end")
+			return nil
+		}
+		if symbol == "@" {
+			if err := x.value(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		key, offset, keyErr := x.next()
+		if keyErr != nil {
+			return keyErr
+		}
+		keyNumber, isNumber := key.(int64)
+		if !isNumber {
+			return fmt.Errorf("Bad key %v isn't a string! ", key)
+		}
+		x.line(offset, depth+1, x.resolve(keyNumber)+":")
+		if err := x.value(depth + 2); err != nil {
+			return err
+		}
+	}
+}
+
+// _explainSymbol 结构符号的含义
+func _explainSymbol(symbol interface{}) string {
+	if symbol == "@" {
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,66 @@
+package gjsonpack
+
+import (
+	"strings"
+	"testing"
+)
+
+// The structure is rendered as a tree with resolved tokens and offsets
+func TestExplain(t *testing.T) {
+	explained, explainErr := Explain("list|world|type^1^2.5^$0|@1|3|4|-1|-3|-4]|2|1]")
+	if explainErr != nil {
+		t.Fatal(explainErr)
+	}
+	expected := `# strings 0-2
+# integers 3-3
+# floats 4-4
+    22  $ object
+    23    0 -> "list":
+    25      @ array
+    26        1 -> "world"
+    28        3 -> 1
+    30        4 -> 2.5
+    32        -1 -> true
+    35        -3 -> null
+    38        -4 -> ""
+    40      ] end
+    42    2 -> "type":
+    44      1 -> "world"
+    45  ] end
+`
+	if explained != expected {
+		t.Fatalf("unexpected explain:\n%s", explained)
+	}
+	// A top-level scalar
+	explained, _ = Explain("hello^^^0")
+	if !strings.HasSuffix(explained, "     8  0 -> \"hello\"\n") {
+		t.Fatalf("unexpected explain:\n%s", explained)
+	}
+	if _, err := Explain("x"); err == nil {
+		t.Fatal("expected an error for bad packed data")
+	}
+}
+
+// Offsets skip the header, anchors and references are marked
+func TestExplainGraph(t *testing.T) {
+	node := &graphNode{Name: "root"}
+	node.Children = []*graphNode{{Name: "child", Parent: node}}
+	packStr, _ := PackWithOptions(node, WithGraph(), WithHeader())
+	explained, explainErr := Explain(packStr)
+	if explainErr != nil {
+		t.Fatal(explainErr)
+	}
+	for _, line := range []string{
+		"# version 1, features 0x1\n",
+		"# integers none\n",
+		"    42  &$ object, anchor 0\n",
+		"    58            ~0 -> anchor 0\n",
+	} {
+		if !strings.Contains(explained, line) {
+			t.Fatalf("%q isn't in:\n%s", line, explained)
+		}
+	}
+	if packStr[42] != '&' || packStr[58] != '~' {
+		t.Fatalf("bad offsets in %s", packStr)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -52,16 +53,51 @@ type astInfo struct {
 
 // Pack 主要对MAP、Struct进行压缩
 func Pack(json interface{}) (string, error) {
-	defer func() (string, error) {
-		return "", errors.New("pack failed")
-	}()
+	return PackOptions{}.Pack(json)
+}
+
+// _pack 按配置压缩 json 参数中的数据
+func _pack(json interface{}, options *PackOptions) (string, error) {
+	packed, features, packedErr := _packBody(json, options)
+	if packedErr != nil {
+		return "", packedErr
+	}
+	return _wrapPacked(packed, packedHeader{Features: features}, options)
+}
+
+// _packBody 压缩 json 参数中的数据, 返回不带版本头的数据及其使用的特性
+func _packBody(json interface{}, options *PackOptions) (string, Feature, error) {
+	return _packBodyStats(json, options, nil)
+}
+
+// _packBodyStats 压缩 json 参数中的数据, stats 不为 nil 时记录压缩统计
+func _packBodyStats(json interface{}, options *PackOptions, stats *Stats) (string, Feature, error) {
 	var dictionaryObj dictionary
 	dictionaryObj.Strings = make(dictionaryString, 0)
 	dictionaryObj.Integers = make(dictionaryIntegers, 0)
 	dictionaryObj.Floats = make(dictionaryFloat, 0)
-	astTree, astTreeErr := recursiveAstBuilder(json, &dictionaryObj)
+	state := _newPackState(&dictionaryObj, options)
+	if stats != nil {
+		state.stats = _newStatsState(stats, 0)
+	}
+	if options.JSCompat {
+		jsValue, jsValueErr := _jsCompatValue(json)
+		if jsValueErr != nil {
+			return "", 0, jsValueErr
+		}
+		json = jsValue
+	}
+	var astTree ast
+	var astTreeErr error
+	var parallel bool
+	if options.Parallelism > 1 && !options.Graph && !options.JSCompat {
+		astTree, parallel, astTreeErr = _buildParallel(json, state)
+	}
+	if !parallel && astTreeErr == nil {
+		astTree, astTreeErr = recursiveAstBuilder(json, state)
+	}
 	if astTreeErr != nil {
-		return "", astTreeErr
+		return "", 0, astTreeErr
 	}
 	// A set of shorthands proxies for the length of the dictionaries
 	var stringLength = dictionaryObj.Strings.Len()
@@ -69,247 +105,464 @@ func Pack(json interface{}) (string, error) {
 	var floatLength = dictionaryObj.Floats.Len()
 	var packed = strings.Join(dictionaryObj.Strings, "|")
 	packed += "^" + strings.Join(dictionaryObj.Integers, "|")
-	packed += "^" + strings.Join(_arrayFloatToArrayString(dictionaryObj.Floats), "|")
+	if options.JSCompat {
+		packed += "^" + strings.Join(_arrayFloatToJSArrayString(dictionaryObj.Floats), "|")
+	} else {
+		packed += "^" + strings.Join(_arrayFloatToArrayString(dictionaryObj.Floats), "|")
+	}
 	// And add the structure
-	recursiveGeneratePackedStr, recursiveGeneratePackedErr := recursiveParser(astTree, stringLength, integerLength, floatLength)
+	var anchorIndex int64
+	recursiveGeneratePackedStr, recursiveGeneratePackedErr := recursiveParser(astTree, stringLength, integerLength, floatLength, &anchorIndex)
 	if recursiveGeneratePackedErr != nil {
-		return "", recursiveGeneratePackedErr
+		return "", 0, recursiveGeneratePackedErr
 	}
 	packed += "^" + recursiveGeneratePackedStr
-	return packed, nil
+	if state.stats != nil {
+		state.stats.finish(state)
+	}
+	var features Feature
+	if anchorIndex > 0 {
+		features |= FeatureGraph
+	}
+	return packed, features, nil
 }
 
 // Unpack 解压 packed 参数中的数据
 func Unpack(packed string, v interface{}) error {
-	unPackerParser, unPackerParserErr := _unpack(packed)
-	if unPackerParserErr != nil {
-		return unPackerParserErr
-	}
-	jsonBytes, jsonMarshalErr := json.Marshal(unPackerParser)
-	if jsonMarshalErr != nil {
-		return jsonMarshalErr
-	}
-	jsonUnmarshalErr := json.Unmarshal(jsonBytes, v)
-	if jsonUnmarshalErr != nil {
-		return jsonUnmarshalErr
-	}
-	return nil
+	return UnpackOptions{}.Unpack(packed, v)
 }
 
 // UnpackToStr 解压 packed 参数中的数据并返回字符串
 func UnpackToStr(packed string) (string, error) {
-	jsonBytes, err := UnpackToBytes(packed)
-	if err != nil {
-		return "", err
-	}
-	return string(jsonBytes), nil
+	return UnpackOptions{}.UnpackToStr(packed)
 }
 
 // UnpackToBytes 解压 packed 参数中的数据并返回字节
 func UnpackToBytes(packed string) ([]byte, error) {
-	unPackerParser, unPackerParserErr := _unpack(packed)
-	if unPackerParserErr != nil {
-		return nil, unPackerParserErr
+	return UnpackOptions{}.UnpackToBytes(packed)
+}
+
+// unpackState 解压过程中的状态
+type unpackState struct {
+	options *UnpackOptions
+	// The header of the packed data
+	header packedHeader
+	// The strings, integers, floats and structure sections
+	sections []string
+	// The byte offset of the structure section
+	structureOffset int64
+	// The dictionary values and their estimated JSON sizes
+	dictionarySlice []interface{}
+	dictionarySizes []int64
+	// The tokens of the structure
+	tokenSlice    []interface{}
+	tokenSliceLen int64
+	// The byte offsets of the tokens in the packed data
+	tokenOffsets []int64
+	// The index of the next token to read
+	tokenSliceIndex int64
+	// The current nesting depth
+	depth int64
+	// The estimated size of the unpacked JSON
+	outputSize int64
+	// The anchors of the shared objects in graph mode
+	anchors []*unpackAnchor
+	// undefined is set when the result contains Undefined
+	undefined bool
+	// The unpacked result
+	result interface{}
+}
+
+// unpackAnchor 图模式下共享对象的锚点
+type unpackAnchor struct {
+	Node interface{}
+	// Size is the estimated JSON size of the node, -1 until the node is closed
+	Size int64
+}
+
+// _unpack 解压 packed 参数中的数据
+func _unpack(packed string, options *UnpackOptions) (interface{}, error) {
+	state, stateErr := _unpackState(packed, options)
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	return state.result, nil
+}
+
+// _unpackState 解压 packed 参数中的数据并返回解压状态
+func _unpackState(packed string, options *UnpackOptions) (*unpackState, error) {
+	state, stateErr := _unpackDictionaries(packed, options)
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	if state.header.Features&FeatureBatch != 0 {
+		return nil, errors.New("The packed data is a batch, use ParseBatch! ")
 	}
-	jsonBytes, jsonMarshalErr := json.Marshal(unPackerParser)
-	if jsonMarshalErr != nil {
-		return nil, jsonMarshalErr
+	if err := state.parseStructure(state.sections[3], state.structureOffset); err != nil {
+		return nil, err
 	}
-	return jsonBytes, nil
+	return state, nil
 }
 
-// _unpack 解压 packed 参数中的数据
-func _unpack(packed string) (interface{}, error) {
+// _unpackDictionaries 解析 packed 参数的版本头与字典, 返回解析结构区之前的解压状态
+func _unpackDictionaries(packed string, options *UnpackOptions) (*unpackState, error) {
+	if err := _checkLimit("MaxInputSize", options.MaxInputSize, int64(len(packed))); err != nil {
+		return nil, err
+	}
+	if options.Encoding != nil {
+		decoded, decodedErr := options.Encoding.Decode(packed)
+		if decodedErr != nil {
+			return nil, decodedErr
+		}
+		packed = decoded
+	}
+	header, body, headerErr := _parseHeader(packed)
+	if headerErr != nil {
+		return nil, headerErr
+	}
+	var bodyOffset int64
+	if header.Version > 0 {
+		// The body follows the header
+		bodyOffset = int64(strings.Index(packed, "^")) + 1
+	}
+	packed = body
+	if header.Features&(FeatureFlate|FeatureGzip) != 0 {
+		// The offsets are in the decompressed body
+		bodyOffset = 0
+		decompressed, decompressedErr := _decompress(packed, header.Features, options.MaxInputSize)
+		if decompressedErr != nil {
+			return nil, decompressedErr
+		}
+		packed = decompressed
+	}
 	// A raw buffer
 	var rawBuffers = strings.Split(packed, "^")
-	dictionarySlice := make([]interface{}, 0)
+	if len(rawBuffers) < 4 {
+		return nil, fmt.Errorf("Bad packed data, expected 4 sections but got %d! ", len(rawBuffers))
+	}
+	state := &unpackState{options: options, header: header, sections: rawBuffers}
+	state.structureOffset = bodyOffset + int64(len(rawBuffers[0])+len(rawBuffers[1])+len(rawBuffers[2])) + 3
+	if err := state.addDictionaries(rawBuffers[0], rawBuffers[1], rawBuffers[2]); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// addDictionaries 追加字典区中的字符串、整数与浮点数
+func (state *unpackState) addDictionaries(stringsBuffer, integersBuffer, floatsBuffer string) error {
 	var buffer string
 	// Add the strings values
-	buffer = rawBuffers[0]
+	buffer = stringsBuffer
 	if buffer != "" {
+		if err := state.growDictionary(buffer); err != nil {
+			return err
+		}
 		bufferSlice := strings.Split(buffer, "|")
 		bufferSliceLen := len(bufferSlice)
 		for i := 0; i < bufferSliceLen; i++ {
-			dictionarySlice = append(dictionarySlice, _decodeStr(bufferSlice[i]))
+			decodeStr := _decodeStr(bufferSlice[i])
+			if err := _checkLimit("MaxStringLength", state.options.MaxStringLength, int64(len(decodeStr))); err != nil {
+				return err
+			}
+			state.dictionarySlice = append(state.dictionarySlice, decodeStr)
+			state.dictionarySizes = append(state.dictionarySizes, int64(len(decodeStr))+2)
 		}
 	}
 	// Add the integers values
-	buffer = rawBuffers[1]
+	buffer = integersBuffer
 	if buffer != "" {
+		if err := state.growDictionary(buffer); err != nil {
+			return err
+		}
 		bufferSlice := strings.Split(buffer, "|")
 		bufferSliceLen := len(bufferSlice)
 		for i := 0; i < bufferSliceLen; i++ {
 			to10Hex, to10HexErr := _baseString36To10(bufferSlice[i])
 			if to10HexErr != nil {
-				return nil, to10HexErr
+				return to10HexErr
 			}
-			dictionarySlice = append(dictionarySlice, to10Hex)
+			state.dictionarySlice = append(state.dictionarySlice, to10Hex)
+			state.dictionarySizes = append(state.dictionarySizes, int64(len(strconv.FormatInt(to10Hex, 10))))
 		}
 	}
 	// Add the floats values
-	buffer = rawBuffers[2]
+	buffer = floatsBuffer
 	if buffer != "" {
+		if err := state.growDictionary(buffer); err != nil {
+			return err
+		}
 		bufferSlice := strings.Split(buffer, "|")
 		bufferSliceLen := len(bufferSlice)
 		for i := 0; i < bufferSliceLen; i++ {
 			to10Hex, to10HexErr := strconv.ParseFloat(bufferSlice[i], 10)
 			if to10HexErr != nil {
-				return nil, to10HexErr
+				return to10HexErr
 			}
-			dictionarySlice = append(dictionarySlice, to10Hex)
+			state.dictionarySlice = append(state.dictionarySlice, to10Hex)
+			state.dictionarySizes = append(state.dictionarySizes, int64(len(bufferSlice[i])))
 		}
 	}
+	return nil
+}
+
+// parseStructure 解析 offset 处的结构区 buffer, 结果写入 state.result
+func (state *unpackState) parseStructure(buffer string, structureOffset int64) error {
+	options := state.options
 	// Tokenizer the structure
-	buffer = rawBuffers[3]
-	tokenSlice := make([]interface{}, 0)
+	state.tokenSlice = make([]interface{}, 0)
 	if buffer != "" {
 		var number36 = ""
 		bufferLen := int64(len(buffer))
 		for i := int64(0); i < bufferLen; i++ {
 			var symbol = _substr(buffer, i, 1)
-			if symbol == "|" || symbol == "$" || symbol == "@" || symbol == "]" {
+			if symbol == "|" || symbol == "$" || symbol == "@" || symbol == "]" || symbol == "&" || symbol == "~" {
 				if number36 != "" {
 					to10Hex, to10HexErr := _baseString36To10(number36)
 					if to10HexErr != nil {
-						return nil, to10HexErr
+						return to10HexErr
 					}
-					tokenSlice = append(tokenSlice, to10Hex)
+					state.tokenSlice = append(state.tokenSlice, to10Hex)
+					state.tokenOffsets = append(state.tokenOffsets, structureOffset+i-int64(len(number36)))
 					number36 = ""
 				}
 				if symbol != "|" {
-					tokenSlice = append(tokenSlice, symbol)
+					state.tokenSlice = append(state.tokenSlice, symbol)
+					state.tokenOffsets = append(state.tokenOffsets, structureOffset+i)
+				}
+				if err := _checkLimit("MaxTokens", options.MaxTokens, int64(len(state.tokenSlice))); err != nil {
+					return err
 				}
 			} else {
 				number36 += symbol
 			}
 		}
+		// A top-level scalar is a lone number
+		if number36 != "" {
+			to10Hex, to10HexErr := _baseString36To10(number36)
+			if to10HexErr != nil {
+				return to10HexErr
+			}
+			state.tokenSlice = append(state.tokenSlice, to10Hex)
+			state.tokenOffsets = append(state.tokenOffsets, structureOffset+bufferLen-int64(len(number36)))
+			if err := _checkLimit("MaxTokens", options.MaxTokens, int64(len(state.tokenSlice))); err != nil {
+				return err
+			}
+		}
 	}
 	// A shorthand proxy for tokenSlice.length
-	var tokenSliceLen = int64(len(tokenSlice))
-	// The index of the next token to read
-	var tokensIndex = int64(0)
-	// A shorthand proxy for dictionarySlice.length
-	var dictionarySliceLen = int64(len(dictionarySlice))
+	state.tokenSliceLen = int64(len(state.tokenSlice))
+	token, tokenErr := state.fetchToken()
+	if tokenErr != nil {
+		return tokenErr
+	}
+	if _, isNumber := token.(int64); isNumber && state.tokenSliceLen != 1 {
+		return errors.New("Bad structure, a top-level value must be alone! ")
+	}
 	// 递归解析
-	unPackerParser, unPackerParserErr := recursiveUnPackerParser(dictionarySlice, tokenSlice, dictionarySliceLen, tokenSliceLen, &tokensIndex)
+	unPackerParser, unPackerParserErr := state.parseValue(token)
 	if unPackerParserErr != nil {
-		return nil, unPackerParserErr
+		return unPackerParserErr
 	}
-	return unPackerParser, nil
+	// Nothing may follow the top-level value
+	if state.tokenSliceIndex+1 != state.tokenSliceLen {
+		return errors.New("Bad structure, unexpected tokens after the top-level value! ")
+	}
+	state.result = unPackerParser
+	return nil
+}
+
+// growDictionary 检查追加 buffer 中的条目后字典是否超出限制
+func (state *unpackState) growDictionary(buffer string) error {
+	entries := int64(len(state.dictionarySlice)) + int64(strings.Count(buffer, "|")) + 1
+	return _checkLimit("MaxDictionaryEntries", state.options.MaxDictionaryEntries, entries)
+}
 
+// addOutput 累加解压后 JSON 的估算长度
+func (state *unpackState) addOutput(size int64) error {
+	state.outputSize += size
+	return _checkLimit("MaxOutputSize", state.options.MaxOutputSize, state.outputSize)
+}
+
+// fetchToken 读取下一个 token
+func (state *unpackState) fetchToken() (interface{}, error) {
+	if state.tokenSliceIndex >= state.tokenSliceLen {
+		return nil, errors.New("Unexpected end of the structure! ")
+	}
+	return state.tokenSlice[state.tokenSliceIndex], nil
+}
+
+// resolveToken 将数值 token 解析为对应的值
+func (state *unpackState) resolveToken(value interface{}) (interface{}, error) {
+	switch value {
+	case tokenTrue:
+		return true, state.addOutput(4)
+	case tokenFalse:
+		return false, state.addOutput(5)
+	case tokenNull:
+		return nil, state.addOutput(4)
+	case tokenUndefined:
+		if state.options.Undefined == UndefinedAsNull {
+			return nil, state.addOutput(4)
+		}
+		state.undefined = true
+		return Undefined, state.addOutput(4)
+	case tokenEmptyString:
+		return "", state.addOutput(2)
+	}
+	fetchIndex, fetchIndexExists := value.(int64)
+	if !fetchIndexExists || fetchIndex < 0 || fetchIndex >= int64(len(state.dictionarySlice)) {
+		return nil, fmt.Errorf("Bad dictionary %v isn't a efficient range! ", value)
+	}
+	return state.dictionarySlice[fetchIndex], state.addOutput(state.dictionarySizes[fetchIndex])
+}
+
+// resolveKey 将 token 解析为对象的键
+func (state *unpackState) resolveKey(nodeKey interface{}) (string, error) {
+	if nodeKey == tokenEmptyString {
+		return "", state.addOutput(3)
+	}
+	fetchIndex, fetchIndexExists := nodeKey.(int64)
+	if !fetchIndexExists || fetchIndex < 0 || fetchIndex >= int64(len(state.dictionarySlice)) {
+		return "", fmt.Errorf("Bad dictionary %v isn't a efficient range! ", nodeKey)
+	}
+	nodeKeyString, nodeKeyIsString := state.dictionarySlice[fetchIndex].(string)
+	if !nodeKeyIsString {
+		return "", fmt.Errorf("Bad key %v isn't a string! ", state.dictionarySlice[fetchIndex])
+	}
+	return nodeKeyString, state.addOutput(state.dictionarySizes[fetchIndex] + 1)
+}
+
+// resolveReference 将 '~' 之后的 token 解析为共享对象
+func (state *unpackState) resolveReference() (interface{}, error) {
+	state.tokenSliceIndex++
+	token, tokenErr := state.fetchToken()
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	anchorIndex, anchorIndexExists := token.(int64)
+	if !anchorIndexExists || anchorIndex < 0 || anchorIndex >= int64(len(state.anchors)) {
+		return nil, fmt.Errorf("Bad reference %v isn't a anchor! ", token)
+	}
+	anchor := state.anchors[anchorIndex]
+	if anchor.Size < 0 {
+		// A cycle, which has no JSON size
+		return anchor.Node, nil
+	}
+	return anchor.Node, state.addOutput(anchor.Size)
+}
+
+// parseValue 解析当前 token 对应的值
+func (state *unpackState) parseValue(value interface{}) (interface{}, error) {
+	switch value {
+	case "@", "$", "&":
+		return recursiveUnPackerParser(state)
+	case "~":
+		return state.resolveReference()
+	}
+	return state.resolveToken(value)
 }
 
 // recursiveUnPackerParser 递归解析
-func recursiveUnPackerParser(dictionarySlice, tokenSlice []interface{}, dictionarySliceLen, tokenSliceLen int64, tokenSliceIndex *int64) (interface{}, error) {
-	defer func() (interface{}, error) {
-		return nil, fmt.Errorf("%s", "unpacked error")
-	}()
+func recursiveUnPackerParser(state *unpackState) (interface{}, error) {
+	token, tokenErr := state.fetchToken()
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	// Maybe '&' (shared object) before the type
+	var anchored = token == "&"
+	if anchored {
+		state.tokenSliceIndex++
+		if token, tokenErr = state.fetchToken(); tokenErr != nil {
+			return nil, tokenErr
+		}
+	}
 	// Maybe '$' (object) or '@' (array)
-	refSymbol := reflect.ValueOf(tokenSlice[*tokenSliceIndex])
-	refSymbolKind := refSymbol.Kind()
-	if refSymbolKind != reflect.String {
-		return nil, fmt.Errorf("Bad token %s isn't a type! ", refSymbolKind.String())
+	symbol, symbolIsString := token.(string)
+	if !symbolIsString || (symbol != "$" && symbol != "@") || (anchored && symbol != "$") {
+		return nil, fmt.Errorf("Bad token %v isn't a type! ", token)
+	}
+	state.tokenSliceIndex++
+	state.depth++
+	defer func() {
+		state.depth--
+	}()
+	if err := _checkLimit("MaxDepth", state.options.MaxDepth, state.depth); err != nil {
+		return nil, err
+	}
+	var outputStart = state.outputSize
+	if err := state.addOutput(2); err != nil {
+		return nil, err
 	}
-	*tokenSliceIndex++
-	var symbol = refSymbol.Interface().(string)
 	switch symbol {
 	case "@":
 		// Parse an array
 		var node = make([]interface{}, 0)
-		for ; *tokenSliceIndex < tokenSliceLen; *tokenSliceIndex++ {
-			var value = tokenSlice[*tokenSliceIndex]
+		for ; state.tokenSliceIndex < state.tokenSliceLen; state.tokenSliceIndex++ {
+			var value = state.tokenSlice[state.tokenSliceIndex]
 			if value == "]" {
 				return node, nil
 			}
-			if value == "@" || value == "$" {
-				var recursiveUnPackerValue, recursiveUnPackerValueErr = recursiveUnPackerParser(dictionarySlice, tokenSlice, dictionarySliceLen, tokenSliceLen, tokenSliceIndex)
-				if recursiveUnPackerValueErr != nil {
-					return nil, recursiveUnPackerValueErr
-				}
-				node = append(node, recursiveUnPackerValue)
-			} else {
-				switch value {
-				case tokenTrue:
-					node = append(node, true)
-					break
-				case tokenFalse:
-					node = append(node, false)
-					break
-				case tokenNull, tokenUndefined:
-					node = append(node, nil)
-					break
-				case tokenEmptyString:
-					node = append(node, "")
-					break
-				default:
-					fetchIndex, fetchIndexExists := value.(int64)
-					if !fetchIndexExists || fetchIndex > dictionarySliceLen {
-						return nil, fmt.Errorf("Bad dictionary %v isn't a efficient range! ", value)
-					}
-					node = append(node, dictionarySlice[fetchIndex])
-					break
+			if len(node) > 0 {
+				if err := state.addOutput(1); err != nil {
+					return nil, err
 				}
 			}
+			nodeValue, nodeValueErr := state.parseValue(value)
+			if nodeValueErr != nil {
+				return nil, nodeValueErr
+			}
+			if nodeValue == Undefined && state.options.Undefined == UndefinedOmit {
+				// Like JSON.stringify, the holes of an array are null
+				nodeValue = nil
+			}
+			node = append(node, nodeValue)
 		}
 		return node, nil
-	case "$":
+	default:
 		// Parse a object
 		var node = make(map[string]interface{}, 0)
-		for ; *tokenSliceIndex < tokenSliceLen; *tokenSliceIndex++ {
-			var nodeKey = tokenSlice[*tokenSliceIndex]
+		var anchor *unpackAnchor
+		if anchored {
+			anchor = &unpackAnchor{Node: node, Size: -1}
+			state.anchors = append(state.anchors, anchor)
+		}
+		for ; state.tokenSliceIndex < state.tokenSliceLen; state.tokenSliceIndex++ {
+			var nodeKey = state.tokenSlice[state.tokenSliceIndex]
 			if nodeKey == "]" {
-				return node, nil
+				break
 			}
-			var nodeKeyString string
-			if nodeKey == tokenEmptyString {
-				nodeKeyString = ""
-			} else {
-				fetchIndex, fetchIndexExists := nodeKey.(int64)
-				if !fetchIndexExists || fetchIndex > dictionarySliceLen {
-					return nil, fmt.Errorf("Bad dictionary %v isn't a efficient range! ", nodeKey)
+			if len(node) > 0 {
+				if err := state.addOutput(1); err != nil {
+					return nil, err
 				}
-				nodeKeyString = dictionarySlice[fetchIndex].(string)
 			}
-
-			*tokenSliceIndex++
-			var value = tokenSlice[*tokenSliceIndex]
-			if value == "@" || value == "$" {
-				var recursiveUnPackerValue, recursiveUnPackerValueErr = recursiveUnPackerParser(dictionarySlice, tokenSlice, dictionarySliceLen, tokenSliceLen, tokenSliceIndex)
-				if recursiveUnPackerValueErr != nil {
-					return nil, recursiveUnPackerValueErr
-				}
-				node[nodeKeyString] = recursiveUnPackerValue
-			} else {
-				switch value {
-				case tokenTrue:
-					node[nodeKeyString] = true
-					break
-				case tokenFalse:
-					node[nodeKeyString] = false
-					break
-				case tokenNull, tokenUndefined:
-					node[nodeKeyString] = nil
-					break
-				case tokenEmptyString:
-					node[nodeKeyString] = ""
-					break
-				default:
-					fetchIndex, fetchIndexExists := value.(int64)
-					if !fetchIndexExists || fetchIndex > dictionarySliceLen {
-						return nil, fmt.Errorf("Bad dictionary %v isn't a efficient range! ", value)
-					}
-					node[nodeKeyString] = dictionarySlice[fetchIndex]
-					break
-				}
+			nodeKeyString, nodeKeyErr := state.resolveKey(nodeKey)
+			if nodeKeyErr != nil {
+				return nil, nodeKeyErr
+			}
+			state.tokenSliceIndex++
+			value, valueErr := state.fetchToken()
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			nodeValue, nodeValueErr := state.parseValue(value)
+			if nodeValueErr != nil {
+				return nil, nodeValueErr
 			}
+			if nodeValue == Undefined && state.options.Undefined == UndefinedOmit {
+				continue
+			}
+			node[nodeKeyString] = nodeValue
+		}
+		if anchor != nil {
+			anchor.Size = state.outputSize - outputStart
 		}
 		return node, nil
 	}
-	return nil, fmt.Errorf("Bad token %s isn't a type! ", refSymbolKind.String())
 }
 
 // recursiveParser 递归语法树解析器
-func recursiveParser(item interface{}, stringLength, integerLength, floatLength int64) (string, error) {
+func recursiveParser(item interface{}, stringLength, integerLength, floatLength int64, anchorIndex *int64) (string, error) {
 	refItem := reflect.ValueOf(item)
 	refItemKind := refItem.Kind()
 	// If the item is Array, then is a object of
@@ -319,11 +572,22 @@ func recursiveParser(item interface{}, stringLength, integerLength, floatLength
 		itemSliceLen := refItem.Len()
 		var packed string
 		if itemSliceLen > 0 {
-			packed = refItem.Index(0).Interface().(string)
+			switch symbol := refItem.Index(0).Interface().(type) {
+			case string:
+				packed = symbol
+			case *astAnchor:
+				// A shared pointer is only marked when something refers to it
+				packed = symbol.Symbol
+				if symbol.Referenced {
+					symbol.Index = *anchorIndex
+					*anchorIndex++
+					packed = "&" + packed
+				}
+			}
 		}
 		if itemSliceLen > 1 {
 			for i := 1; i < itemSliceLen; i++ {
-				recursiveGeneratePackedStr, recursiveGeneratePackedErr := recursiveParser(refItem.Index(i).Interface(), stringLength, integerLength, floatLength)
+				recursiveGeneratePackedStr, recursiveGeneratePackedErr := recursiveParser(refItem.Index(i).Interface(), stringLength, integerLength, floatLength, anchorIndex)
 				if recursiveGeneratePackedErr != nil {
 					return "", recursiveGeneratePackedErr
 				}
@@ -339,6 +603,10 @@ func recursiveParser(item interface{}, stringLength, integerLength, floatLength
 	if refItemKind != reflect.Struct {
 		return "", errors.New("The item is alien! ")
 	}
+	if currentAstReference, currentAstReferenceExists := refItem.Interface().(astReference); currentAstReferenceExists {
+		// Return a reference to the anchor of the shared pointer
+		return "~" + _baseInt10To36(currentAstReference.Anchor.Index), nil
+	}
 	currentAstInfo, currentAstInfoExists := refItem.Interface().(astInfo)
 	if !currentAstInfoExists {
 		return "", errors.New("The item is alien! ")
@@ -361,105 +629,169 @@ func recursiveParser(item interface{}, stringLength, integerLength, floatLength
 }
 
 // recursiveAstBuilder 递归语法树生成
-func recursiveAstBuilder(item interface{}, dictionaryObj *dictionary) (ast, error) {
+func recursiveAstBuilder(item interface{}, state *packState) (ast, error) {
+	if object, isObject := item.(jsObject); isObject {
+		return object.buildAst(state)
+	}
+	if _, isUndefined := item.(UndefinedType); isUndefined {
+		// The item is undefined
+		return state.literal(astInfo{Type: "undefined", Index: tokenUndefined}), nil
+	}
+	if number, isNumber := item.(json.Number); isNumber {
+		// Like encoding/json, the number is written as a number, and integers keep their precision
+		if integer, integerErr := number.Int64(); integerErr == nil {
+			return assertionIntegers(integer, state), nil
+		}
+		float, floatErr := number.Float64()
+		if floatErr != nil {
+			return nil, fmt.Errorf("Bad number %q at %s! ", string(number), "$"+strings.Join(state.path, ""))
+		}
+		return recursiveAstBuilder(float, state)
+	}
 	refItem := reflect.ValueOf(item)
 	refItemKind := refItem.Kind()
 	if refItemKind == reflect.Invalid {
 		// The item is null
-		return astInfo{Type: "null", Index: tokenNull}, nil
+		return state.literal(astInfo{Type: "null", Index: tokenNull}), nil
 	}
 	switch refItemKind {
 	case reflect.Slice, reflect.Array:
+		if refItemKind == reflect.Slice {
+			leave, visitErr := state.visit(refItem)
+			if visitErr != nil {
+				return nil, visitErr
+			}
+			defer leave()
+		}
 		// The item is Array Object
+		state.enterContainer()
 		astArray := make([]interface{}, 0)
 		astArray = append(astArray, "@")
 		itemSliceLen := refItem.Len()
 		for i := 0; i < itemSliceLen; i++ {
-			builderArrayAst, builderArrayAstErr := recursiveAstBuilder(refItem.Index(i).Interface(), dictionaryObj)
+			state.path = append(state.path, "["+strconv.Itoa(i)+"]")
+			builderArrayAst, builderArrayAstErr := recursiveAstBuilder(refItem.Index(i).Interface(), state)
+			state.path = state.path[:len(state.path)-1]
 			if builderArrayAstErr != nil {
 				return nil, builderArrayAstErr
 			}
 			astArray = append(astArray, builderArrayAst)
 		}
+		state.leaveContainer(astArray)
 		return astArray, nil
 	case reflect.Map:
+		leave, visitErr := state.visit(refItem)
+		if visitErr != nil {
+			return nil, visitErr
+		}
+		defer leave()
+		state.enterContainer()
 		astMap := make([]interface{}, 0)
 		astMap = append(astMap, "$")
-		for _, refNodeKey := range refItem.MapKeys() {
-			builderMapKeyAst, builderMapKeyAstErr := recursiveAstBuilder(refNodeKey.Interface(), dictionaryObj)
+		refNodeKeys := refItem.MapKeys()
+		nodeKeys := make([]string, len(refNodeKeys))
+		for i, refNodeKey := range refNodeKeys {
+			nodeKey, nodeKeyErr := _mapKeyString(refNodeKey)
+			if nodeKeyErr != nil {
+				return nil, nodeKeyErr
+			}
+			nodeKeys[i] = nodeKey
+		}
+		if state.options.SortKeys {
+			sort.Sort(mapKeySorter{refNodeKeys: refNodeKeys, nodeKeys: nodeKeys})
+		}
+		for i, refNodeKey := range refNodeKeys {
+			builderMapKeyAst, builderMapKeyAstErr := recursiveAstBuilder(nodeKeys[i], state)
 			if builderMapKeyAstErr != nil {
 				return nil, builderMapKeyAstErr
 			}
 			astMap = append(astMap, builderMapKeyAst)
-			builderMapValueAst, builderMapValueAstErr := recursiveAstBuilder(refItem.MapIndex(refNodeKey).Interface(), dictionaryObj)
+			state.path = append(state.path, "."+nodeKeys[i])
+			builderMapValueAst, builderMapValueAstErr := recursiveAstBuilder(refItem.MapIndex(refNodeKey).Interface(), state)
+			state.path = state.path[:len(state.path)-1]
 			if builderMapValueAstErr != nil {
 				return nil, builderMapValueAstErr
 			}
 			astMap = append(astMap, builderMapValueAst)
 		}
+		state.leaveContainer(astMap)
 		return astMap, nil
 	case reflect.Struct:
+		if packer, isPacker := item.(Packer); isPacker {
+			// The item has a generated PackGJSON method
+			encoder := &Encoder{state: state}
+			if err := packer.PackGJSON(encoder); err != nil {
+				return nil, err
+			}
+			if encoder.err != nil {
+				return nil, encoder.err
+			}
+			return encoder.result, nil
+		}
 		// The item is Object
+		state.enterContainer()
 		astStruct := make([]interface{}, 0)
 		astStruct = append(astStruct, "$")
-		refItemType := refItem.Type()
-		fieldLen := refItemType.NumField()
-		for i := 0; i < fieldLen; i++ {
-			objField := refItemType.Field(i)
-			var structName string
-			structJsonTagName := objField.Tag.Get("json")
-			if structJsonTagName == "-" {
+		for _, field := range _structFields(refItem.Type()) {
+			if field.OmitEmpty && _isEmptyValue(refItem.Field(field.Index)) {
 				continue
 			}
-			if structJsonTagName == "" {
-				structJsonTagName = objField.Name
-			}
-			structName = structJsonTagName
-
-			builderStructNameAst, builderStructNameAstErr := recursiveAstBuilder(structName, dictionaryObj)
+			builderStructNameAst, builderStructNameAstErr := recursiveAstBuilder(field.Name, state)
 			if builderStructNameAstErr != nil {
 				return nil, builderStructNameAstErr
 			}
 			astStruct = append(astStruct, builderStructNameAst)
-			builderStructValueAst, builderStructValueAstErr := recursiveAstBuilder(refItem.Field(i).Interface(), dictionaryObj)
+			state.path = append(state.path, "."+field.Name)
+			builderStructValueAst, builderStructValueAstErr := recursiveAstBuilder(refItem.Field(field.Index).Interface(), state)
+			state.path = state.path[:len(state.path)-1]
 			if builderStructValueAstErr != nil {
 				return nil, builderStructValueAstErr
 			}
 			astStruct = append(astStruct, builderStructValueAst)
 		}
+		state.leaveContainer(astStruct)
 		return astStruct, nil
 	case reflect.String:
 		// The item is String
 		itemString := refItem.String()
 		if len(itemString) <= 0 {
 			// The item empty string
-			return astInfo{Type: "empty", Index: tokenEmptyString}, nil
-		}
-		// The index of that word in the dictionary
-		index := _indexOf(dictionaryObj.Strings, itemString)
-		// If not, add to the dictionary and actualize the index
-		if index == -1 {
-			dictionaryObj.Strings = append(dictionaryObj.Strings, _encodeStr(itemString))
-			index = dictionaryObj.Strings.Len() - 1
+			return state.literal(astInfo{Type: "empty", Index: tokenEmptyString}), nil
 		}
-		return astInfo{Type: "strings", Index: index}, nil
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return assertionString(itemString, state), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		// The item is integer
-		return assertionIntegers(refItem.Int(), dictionaryObj), nil
+		return assertionIntegers(refItem.Int(), state), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// The item is unsigned integer
+		itemUint := refItem.Uint()
+		if itemUint > math.MaxInt64 {
+			return nil, fmt.Errorf("Unsigned integer %d overflows int64 at %s! ", itemUint, "$"+strings.Join(state.path, ""))
+		}
+		return assertionIntegers(int64(itemUint), state), nil
 	case reflect.Float32, reflect.Float64:
 		// The item is float
 		itemFloat := refItem.Float()
-		// check number is integer
-		if math.Mod(itemFloat, 1) == 0 {
+		// check number is integer within int64
+		if integer, isInteger := _floatToInt64(itemFloat); isInteger {
 			// The item is integer
-			return assertionIntegers(int64(itemFloat), dictionaryObj), nil
+			return assertionIntegers(integer, state), nil
 		}
 		// The item is float
-		return assertionFloat(itemFloat, dictionaryObj), nil
-	case reflect.Interface, reflect.Ptr:
-		t1 := refItem.Elem().Interface()
-		return recursiveAstBuilder(t1, dictionaryObj)
+		return assertionFloat(itemFloat, state), nil
+	case reflect.Ptr:
+		if refItem.IsNil() {
+			return state.literal(astInfo{Type: "null", Index: tokenNull}), nil
+		}
+		if state.options.Graph && (refItem.Elem().Kind() == reflect.Struct || refItem.Elem().Kind() == reflect.Map) {
+			return state.anchor(refItem)
+		}
+		leave, visitErr := state.visit(refItem)
+		if visitErr != nil {
+			return nil, visitErr
+		}
+		defer leave()
+		return recursiveAstBuilder(refItem.Elem().Interface(), state)
 	case reflect.Bool:
 		// The item is boolean
 		var index int64
@@ -468,13 +800,40 @@ func recursiveAstBuilder(item interface{}, dictionaryObj *dictionary) (ast, erro
 		} else {
 			index = tokenFalse
 		}
-		return astInfo{Type: "boolean", Index: index}, nil
+		return state.literal(astInfo{Type: "boolean", Index: index}), nil
 	}
 	return nil, errors.New("Unexpected argument of type " + refItemKind.String())
 }
 
+// assertionString 断言字符串
+func assertionString(str string, state *packState) astInfo {
+	dictionaryObj := state.dictionaryObj
+	encodeStr := _encodeStr(str)
+	// The index of that word in the dictionary; like jsonpack, JSCompat looks up the raw string,
+	// which reuses the entry of another string whose escaped form equals it
+	lookupStr := encodeStr
+	if state.options.JSCompat {
+		lookupStr = str
+	}
+	index := _indexOf(dictionaryObj.Strings, lookupStr)
+	// If not, add to the dictionary and actualize the index
+	if index == -1 {
+		state.escapeBytes += int64(len(encodeStr) - len(str))
+		dictionaryObj.Strings = append(dictionaryObj.Strings, encodeStr)
+		index = dictionaryObj.Strings.Len() - 1
+		if state.stats != nil {
+			state.stats.stringSizes = append(state.stats.stringSizes, _jsonStringSize(str))
+		}
+	}
+	if state.stats != nil {
+		state.addToken(state.stats.stringSizes[index])
+	}
+	return astInfo{Type: "strings", Index: index}
+}
+
 // assertionIntegers 断言整数
-func assertionIntegers(number int64, dictionaryObj *dictionary) astInfo {
+func assertionIntegers(number int64, state *packState) astInfo {
+	dictionaryObj := state.dictionaryObj
 	// The index of that number in the dictionary
 	index := _indexOf(dictionaryObj.Integers, number)
 	if index == -1 {
@@ -482,17 +841,27 @@ func assertionIntegers(number int64, dictionaryObj *dictionary) astInfo {
 		dictionaryObj.Integers = append(dictionaryObj.Integers, _baseInt10To36(number))
 		index = int64(len(dictionaryObj.Integers) - 1)
 	}
+	if state.stats != nil {
+		state.addToken(int64(len(strconv.FormatInt(number, 10))))
+	}
 	return astInfo{Type: "integers", Index: index}
 }
 
 // assertionFloat 断言浮点数
-func assertionFloat(number float64, dictionaryObj *dictionary) astInfo {
+func assertionFloat(number float64, state *packState) astInfo {
+	dictionaryObj := state.dictionaryObj
 	// The index of that number in the dictionary
 	index := _indexOf(dictionaryObj.Floats, number)
 	if index == -1 {
 		// If not, add to the dictionary and actualize the index
 		dictionaryObj.Floats = append(dictionaryObj.Floats, number)
 		index = int64(len(dictionaryObj.Floats) - 1)
+		if state.stats != nil {
+			state.stats.floatSizes = append(state.stats.floatSizes, _jsonFloatSize(number))
+		}
+	}
+	if state.stats != nil {
+		state.addToken(state.stats.floatSizes[index])
 	}
 	return astInfo{Type: "floats", Index: index}
 }
@@ -625,6 +994,15 @@ func _arrayFloatToArrayString(arr []float64) []string {
 	return arrString
 }
 
+// _arrayFloatToJSArrayString 数组类型转换, 与 JavaScript 的格式一致
+func _arrayFloatToJSArrayString(arr []float64) []string {
+	arrString := make([]string, len(arr))
+	for i := 0; i < len(arr); i++ {
+		arrString[i] = _formatJSNumber(arr[i])
+	}
+	return arrString
+}
+
 // _formatFloat 主要逻辑就是先乘，trunc之后再除回去，就达到了保留N位小数的效果
 func _formatFloat(num float64, decimal int) string {
 	// 默认乘1
@@ -3,6 +3,9 @@ package gjsonpack
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -57,3 +60,116 @@ func TestDeCompress(t *testing.T) {
 	}
 	fmt.Printf("jsonMap:%v\n", jsonMap)
 }
+
+// Decompression with resource limits
+func TestUnpackLimits(t *testing.T) {
+	packStr := "type|world|name|earth|children|continent|America|country|Chile|commune|Antofagasta|Europe^^^$0|1|2|3|4|@$0|5|2|6|4|@$0|7|2|8|4|@$0|9|2|A]]]]]|$0|5|2|B]]]"
+	limits := map[string]UnpackOptions{
+		"MaxInputSize":         {MaxInputSize: 32},
+		"MaxDictionaryEntries": {MaxDictionaryEntries: 5},
+		"MaxStringLength":      {MaxStringLength: 8},
+		"MaxTokens":            {MaxTokens: 10},
+		"MaxDepth":             {MaxDepth: 3},
+		"MaxOutputSize":        {MaxOutputSize: 64},
+	}
+	for limit, options := range limits {
+		_, err := options.UnpackToBytes(packStr)
+		limitErr, ok := err.(*LimitError)
+		if !ok || limitErr.Limit != limit {
+			t.Fatalf("%s: expected a limit error, got %v", limit, err)
+		}
+	}
+	jsonMap := make(map[string]interface{}, 0)
+	options := UnpackOptions{MaxInputSize: 1024, MaxDepth: 8, MaxOutputSize: 1024}
+	if err := options.Unpack(packStr, &jsonMap); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Decompression of malformed data
+func TestUnpackMalformed(t *testing.T) {
+	for _, packStr := range []string{"", "a^b", "a^^^$0|", "a^^^$1|0]", "^1^^$0|0]", "a^^^@$0|", "a^^^@0]ZZZ", "0^^^$]1"} {
+		if _, err := UnpackToBytes(packStr); err == nil {
+			t.Fatalf("%q: expected an error", packStr)
+		}
+	}
+}
+
+// Compression and decompression of top-level scalars
+func TestTopLevelScalars(t *testing.T) {
+	scalars := map[string]interface{}{
+		`"hello world"`: "hello world",
+		`""`:            "",
+		`42`:            42,
+		`-7`:            int8(-7),
+		`2.5`:           2.5,
+		`true`:          true,
+		`false`:         false,
+		`null`:          nil,
+	}
+	for expected, scalar := range scalars {
+		packStr, packErr := Pack(scalar)
+		if packErr != nil {
+			t.Fatal(packErr)
+		}
+		jsonStr, unPackErr := UnpackToStr(packStr)
+		if unPackErr != nil {
+			t.Fatalf("%s: %v", packStr, unPackErr)
+		}
+		if jsonStr != expected {
+			t.Fatalf("%s: expected %s, got %s", packStr, expected, jsonStr)
+		}
+	}
+	var unpacked string
+	if err := Unpack("hello+world^^^0", &unpacked); err != nil || unpacked != "hello world" {
+		t.Fatalf("unexpected unpacked %q %v", unpacked, err)
+	}
+	for _, packStr := range []string{"a^^^", "a^^^0|0", "a^^^1"} {
+		if _, err := UnpackToStr(packStr); err == nil {
+			t.Fatalf("%q: expected an error", packStr)
+		}
+	}
+}
+
+// Unsigned integers beyond int64 are rejected instead of wrapping
+func TestPackUintOverflow(t *testing.T) {
+	packStr, packErr := Pack(map[string]interface{}{"max": uint64(math.MaxInt64)})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if jsonStr, _ := UnpackToStr(packStr); jsonStr != `{"max":9223372036854775807}` {
+		t.Fatalf("unexpected json %s", jsonStr)
+	}
+	_, packErr = Pack(map[string]interface{}{"id": []uint64{1, 1 << 63}})
+	if packErr == nil || !strings.Contains(packErr.Error(), "$.id[1]") {
+		t.Fatalf("expected an overflow at $.id[1], got %v", packErr)
+	}
+}
+
+// json.Number packs as a number like in encoding/json
+func TestPackJSONNumber(t *testing.T) {
+	packStr, packErr := PackWithOptions(map[string]interface{}{"id": json.Number("9007199254740993"), "ratio": json.Number("0.5"), "whole": json.Number("2.0")}, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "id|ratio|whole^2GOSA7PA2GX|2^0.5^$0|3|1|5|2|4]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	if _, packErr = Pack(json.Number("x")); packErr == nil {
+		t.Fatal("expected a bad number")
+	}
+	// Whole numbers outside int64 are stored as floats
+	for _, item := range []interface{}{json.Number("100000000000000000000"), 1e20, -1e20, float64(1 << 63)} {
+		packStr, packErr = Pack(map[string]interface{}{"big": item})
+		if packErr != nil {
+			t.Fatal(packErr)
+		}
+		var unpacked map[string]float64
+		if err := Unpack(packStr, &unpacked); err != nil {
+			t.Fatal(err)
+		}
+		if expected, _ := strconv.ParseFloat(fmt.Sprint(item), 64); unpacked["big"] != expected {
+			t.Fatalf("%v: unexpected %s", item, packStr)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package gjsonpackhttp 在 net/http 中协商 gjsonpack 压缩的请求与响应
+package gjsonpackhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+// ContentType gjsonpack 压缩数据的媒体类型
+const ContentType = "application/x-jsonpack"
+
+// jsonContentType JSON 的媒体类型
+const jsonContentType = "application/json"
+
+// DefaultMaxBodySize 默认的压缩体大小上限, 解压后的 JSON 也受此限制
+const DefaultMaxBodySize = 10 << 20
+
+// ErrBodyTooLarge 压缩体或其解压结果超出大小上限
+var ErrBodyTooLarge = errors.New("gjsonpackhttp: body too large")
+
+// Middleware 服务端中间件: 客户端的 Accept 包含 ContentType 时压缩 JSON 响应,
+// Content-Type 为 ContentType 的请求体解压为 JSON 后交给处理器
+type Middleware struct {
+	// PackOptions 压缩响应的选项, 默认按键排序, 数字保持 JSON 中的精度;
+	// gjsonpack.WithJSCompat 使输出与 JavaScript 的 jsonpack 库一致, 但超过 2^53 的整数会失去精度
+	PackOptions []gjsonpack.PackOption
+	// UnpackOptions 解压请求体的选项, 如 gjsonpack.WithLimits
+	UnpackOptions []gjsonpack.UnpackOption
+	// MaxBodySize 压缩的请求体及其解压结果的大小上限, 超出时响应 413,
+	// 0 时为 DefaultMaxBodySize, 小于 0 时不限制
+	MaxBodySize int64
+}
+
+// Handler 使用默认配置的中间件
+func Handler(next http.Handler) http.Handler {
+	return (&Middleware{}).Handler(next)
+}
+
+// Handler 包装 next
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _isMediaType(r.Header.Get("Content-Type"), ContentType) {
+			if err := m.unpackRequest(r); err != nil {
+				status := http.StatusBadRequest
+				if _isTooLarge(err) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+		writer := &responseWriter{ResponseWriter: w, middleware: m, pack: Accepts(r.Header)}
+		next.ServeHTTP(writer, r)
+		writer.finish()
+	})
+}
+
+// unpackRequest 将请求体解压为 JSON
+func (m *Middleware) unpackRequest(r *http.Request) error {
+	packed, packedErr := _readBody(r.Body, m.MaxBodySize)
+	_ = r.Body.Close()
+	if packedErr != nil {
+		return packedErr
+	}
+	jsonBytes, jsonErr := _unpackBody(packed, m.MaxBodySize, m.UnpackOptions)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	r.Body = io.NopCloser(bytes.NewReader(jsonBytes))
+	r.ContentLength = int64(len(jsonBytes))
+	r.Header.Set("Content-Type", jsonContentType)
+	r.Header.Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	return nil
+}
+
+// _maxBodySize 实际的大小上限, 小于 0 时不限制
+func _maxBodySize(maxBodySize int64) int64 {
+	if maxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+	return maxBodySize
+}
+
+// _readBody 读取 body, 超出大小上限时返回已读取的内容与 ErrBodyTooLarge
+func _readBody(body io.Reader, maxBodySize int64) ([]byte, error) {
+	max := _maxBodySize(maxBodySize)
+	if max < 0 {
+		return io.ReadAll(body)
+	}
+	data, dataErr := io.ReadAll(io.LimitReader(body, max+1))
+	if dataErr != nil {
+		return nil, dataErr
+	}
+	if int64(len(data)) > max {
+		return data, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+// _unpackBody 将压缩体解压为 JSON, DEFLATE、gzip 解压后的数据与解压结果同样受大小上限限制
+func _unpackBody(packed []byte, maxBodySize int64, unpackOptions []gjsonpack.UnpackOption) ([]byte, error) {
+	var opts []gjsonpack.UnpackOption
+	if max := _maxBodySize(maxBodySize); max > 0 {
+		// MaxInputSize also bounds the decompressed data of a flate or gzip body
+		opts = append(opts, gjsonpack.WithMaxInputSize(max), gjsonpack.WithMaxOutputSize(max))
+	}
+	// The options of the caller come last and override the limit
+	opts = append(opts, unpackOptions...)
+	jsonBytes, jsonErr := gjsonpack.NewUnpackOptions(opts...).UnpackToBytes(string(packed))
+	if jsonErr != nil {
+		return nil, fmt.Errorf("gjsonpack: %w", jsonErr)
+	}
+	return jsonBytes, nil
+}
+
+// _isTooLarge err 是否为超出大小上限
+func _isTooLarge(err error) bool {
+	var limitErr *gjsonpack.LimitError
+	return errors.Is(err, ErrBodyTooLarge) || (errors.As(err, &limitErr) && (limitErr.Limit == "MaxInputSize" || limitErr.Limit == "MaxOutputSize"))
+}
+
+// _packJSON 压缩 JSON 文本, 默认按键排序, 数字以 json.Number 保持精度,
+// 开启 JSCompat 时与 JavaScript 的 jsonpack 库一致
+func _packJSON(jsonBytes []byte, packOptions []gjsonpack.PackOption) (string, error) {
+	options := gjsonpack.NewPackOptions(append([]gjsonpack.PackOption{gjsonpack.WithSortedKeys()}, packOptions...)...)
+	if options.JSCompat {
+		return options.Pack(json.RawMessage(jsonBytes))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return "", errors.New("invalid JSON: data after the top-level value")
+	}
+	return options.Pack(value)
+}
+
+// responseWriter 缓冲需要压缩的响应
+type responseWriter struct {
+	http.ResponseWriter
+	middleware *Middleware
+	// pack is set when the client accepts packed responses
+	pack bool
+	// buffering is set when the response is JSON that will be packed
+	buffering   bool
+	status      int
+	wroteHeader bool
+	buffer      bytes.Buffer
+}
+
+// WriteHeader 记录状态码, 按 Content-Type 决定是否缓冲, 不缓冲时直接写入
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	header := w.Header()
+	isJSON := _isMediaType(header.Get("Content-Type"), jsonContentType)
+	if isJSON {
+		// The representation depends on Accept
+		header.Add("Vary", "Accept")
+	}
+	w.buffering = w.pack && isJSON && header.Get("Content-Encoding") == ""
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write 写入响应体, 需要压缩时缓冲
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.buffering {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buffer.Write(data)
+}
+
+// Flush 将不压缩的响应发送给客户端, 缓冲的 JSON 在处理结束后压缩发送
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return
+	}
+	if flusher, isFlusher := w.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层的 http.ResponseWriter, 供 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish 写入缓冲的响应, JSON 响应压缩后写入
+func (w *responseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.buffering {
+		return
+	}
+	header := w.Header()
+	body := w.buffer.Bytes()
+	if len(body) > 0 {
+		if packed, packedErr := _packJSON(body, w.middleware.PackOptions); packedErr == nil {
+			// Invalid JSON is sent as it is
+			body = []byte(packed)
+			header.Set("Content-Type", ContentType)
+			header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// Accepts header 的 Accept 是否明确包含 ContentType
+func Accepts(header http.Header) bool {
+	for _, accept := range header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil || mediaType != ContentType {
+				continue
+			}
+			if q, qErr := strconv.ParseFloat(params["q"], 64); qErr == nil && q <= 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// _isMediaType value 的媒体类型是否为 mediaType
+func _isMediaType(value, mediaType string) bool {
+	parsed, _, err := mime.ParseMediaType(value)
+	return err == nil && parsed == mediaType
+}
@@ -0,0 +1,215 @@
+package gjsonpackhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+// jsonHandler 返回固定的 JSON, 并回显请求体
+func jsonHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write([]byte(`{"type":"world","name":"earth"}`))
+}
+
+// Packed responses are negotiated through Accept
+func TestMiddlewareResponse(t *testing.T) {
+	handler := Handler(http.HandlerFunc(jsonHandler))
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept", "application/json;q=0.5, application/x-jsonpack")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != ContentType || recorder.Header().Get("Vary") != "Accept" {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder.Body.String() != "name|earth|type|world^^^$0|1|2|3]" || recorder.Header().Get("Content-Length") != "33" {
+		t.Fatalf("unexpected body %s", recorder.Body.String())
+	}
+	// Without Accept the JSON is unchanged, but still varies
+	for _, accept := range []string{"", "application/json", "application/x-jsonpack;q=0"} {
+		request = httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Accept", accept)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Body.String() != `{"type":"world","name":"earth"}` || recorder.Header().Get("Vary") != "Accept" ||
+			recorder.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Fatalf("Accept %q: unexpected response %v %s", accept, recorder.Header(), recorder.Body.String())
+		}
+	}
+}
+
+// Other responses pass through
+func TestMiddlewarePassThrough(t *testing.T) {
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/text":
+			_, _ = w.Write([]byte("hello world"))
+		case "/invalid":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTeapot)
+			_, _ = w.Write([]byte("{"))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	for path, expected := range map[string]string{"/text": "hello world", "/invalid": "{", "/empty": ""} {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Accept", ContentType)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Body.String() != expected || recorder.Header().Get("Content-Type") == ContentType {
+			t.Fatalf("%s: unexpected response %v %s", path, recorder.Header(), recorder.Body.String())
+		}
+	}
+}
+
+// Only JSON responses are buffered, others are streamed and flushed
+func TestMiddlewareFlush(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || unwrapper.Unwrap() != recorder {
+			t.Fatal("expected Unwrap to return the recorder")
+		}
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = w.Write([]byte(`{"type":"world"}`))
+		w.(http.Flusher).Flush()
+		if streamed := recorder.Body.Len() > 0; streamed != (r.URL.Query().Get("type") == "text/event-stream") {
+			t.Fatalf("%s: unexpected body %s", r.URL.Query().Get("type"), recorder.Body.String())
+		}
+	}))
+	for _, contentType := range []string{"text/event-stream", "application/json"} {
+		recorder = httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/?type="+url.QueryEscape(contentType), nil)
+		request.Header.Set("Accept", ContentType)
+		handler.ServeHTTP(recorder, request)
+		packed := recorder.Header().Get("Content-Type") == ContentType
+		if packed != (contentType == "application/json") {
+			t.Fatalf("%s: unexpected response %v %s", contentType, recorder.Header(), recorder.Body.String())
+		}
+		if contentType == "text/event-stream" && !recorder.Flushed {
+			t.Fatalf("%s: expected a flush", contentType)
+		}
+	}
+}
+
+// Packed request bodies reach the handler as JSON
+func TestMiddlewareRequest(t *testing.T) {
+	handler := (&Middleware{UnpackOptions: []gjsonpack.UnpackOption{gjsonpack.WithMaxInputSize(64)}}).Handler(http.HandlerFunc(jsonHandler))
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("type|world^^^$0|1]"))
+	request.Header.Set("Content-Type", ContentType)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Body.String() != `{"type":"world"}` || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %v %s", recorder.Header(), recorder.Body.String())
+	}
+	bodies := map[string]int{
+		"x":                              http.StatusBadRequest,
+		strings.Repeat("a", 65) + "^^^0": http.StatusRequestEntityTooLarge,
+	}
+	for body, status := range bodies {
+		request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", ContentType)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != status {
+			t.Fatalf("expected %d, got %d", status, recorder.Code)
+		}
+	}
+}
+
+// Packed request bodies and their JSON are limited by MaxBodySize
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	handler := (&Middleware{MaxBodySize: 32}).Handler(http.HandlerFunc(jsonHandler))
+	// The packed body of inflated fits, its JSON doesn't
+	inflated := strings.Repeat("a", 10) + "^^^@0|0|0]"
+	bodies := map[string]int{
+		strings.Repeat("a", 40) + "^^^0": http.StatusRequestEntityTooLarge,
+		inflated:                         http.StatusRequestEntityTooLarge,
+		"a^^^0":                          http.StatusOK,
+	}
+	for body, status := range bodies {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", ContentType)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != status {
+			t.Fatalf("%s: expected %d, got %d", body, status, recorder.Code)
+		}
+	}
+}
+
+// A small compressed body stops decompressing at MaxBodySize
+func TestMiddlewareDecompressionBomb(t *testing.T) {
+	handler := (&Middleware{MaxBodySize: 8192}).Handler(http.HandlerFunc(jsonHandler))
+	for _, compression := range []gjsonpack.Compression{gjsonpack.CompressionFlate, gjsonpack.CompressionGzip} {
+		bomb, _ := gjsonpack.PackWithOptions(strings.Repeat("a", 1<<22), gjsonpack.WithCompression(compression))
+		if len(bomb) > 8192 {
+			t.Fatalf("%s: the bomb has %d bytes", compression, len(bomb))
+		}
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(bomb))
+		request.Header.Set("Content-Type", ContentType)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected %d, got %d", compression, http.StatusRequestEntityTooLarge, recorder.Code)
+		}
+		var limitErr *gjsonpack.LimitError
+		if _, err := _unpackBody([]byte(bomb), 8192, nil); !errors.As(err, &limitErr) || limitErr.Limit != "MaxInputSize" {
+			t.Fatalf("%s: expected a MaxInputSize error, got %v", compression, err)
+		}
+	}
+}
+
+// Integers beyond 2^53 keep their precision unless JSCompat is requested
+func TestMiddlewareLargeIntegers(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":9007199254740993}`))
+	}
+	for _, test := range []struct {
+		middleware *Middleware
+		expected   string
+	}{
+		{&Middleware{}, `{"id":9007199254740993}`},
+		{&Middleware{PackOptions: []gjsonpack.PackOption{gjsonpack.WithJSCompat()}}, `{"id":9007199254740992}`},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Accept", ContentType)
+		recorder := httptest.NewRecorder()
+		test.middleware.Handler(http.HandlerFunc(handler)).ServeHTTP(recorder, request)
+		jsonStr, err := gjsonpack.UnpackToStr(recorder.Body.String())
+		if err != nil || jsonStr != test.expected {
+			t.Fatalf("expected %s, got %s: %v", test.expected, jsonStr, err)
+		}
+	}
+}
+
+// A real server with a Go client
+func TestMiddlewareServer(t *testing.T) {
+	server := httptest.NewServer(Handler(http.HandlerFunc(jsonHandler)))
+	defer server.Close()
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("Accept", ContentType)
+	response, responseErr := server.Client().Do(request)
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	defer response.Body.Close()
+	packed, _ := io.ReadAll(response.Body)
+	var planet map[string]string
+	if err := gjsonpack.Unpack(string(packed), &planet); err != nil || planet["name"] != "earth" {
+		t.Fatalf("unexpected planet %v: %v", planet, err)
+	}
+}
@@ -0,0 +1,116 @@
+package gjsonpackhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+// Transport 客户端的 http.RoundTripper: 请求的 Accept 加上 ContentType,
+// 压缩的响应体解压为 JSON, 调用方按 JSON 读取响应
+type Transport struct {
+	// Base 发送请求的 http.RoundTripper, nil 时使用 http.DefaultTransport
+	Base http.RoundTripper
+	// PackRequests 是否压缩 JSON 请求体, 服务端需要能解压, 如使用 Middleware
+	PackRequests bool
+	// PackOptions 压缩请求体的选项, 默认按键排序, 数字保持 JSON 中的精度, 见 Middleware.PackOptions
+	PackOptions []gjsonpack.PackOption
+	// UnpackOptions 解压响应体的选项, 如 gjsonpack.WithLimits
+	UnpackOptions []gjsonpack.UnpackOption
+	// MaxBodySize 压缩的响应体及其解压结果的大小上限, 超出时返回 ErrBodyTooLarge,
+	// 更大的 JSON 请求体不压缩; 0 时为 DefaultMaxBodySize, 小于 0 时不限制
+	MaxBodySize int64
+}
+
+// NewClient 使用 Transport 的 http.Client, base 为 nil 时使用 http.DefaultTransport
+func NewClient(base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: &Transport{Base: base}}
+}
+
+// base 返回发送请求的 http.RoundTripper
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip 发送请求, 实现 http.RoundTripper
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	r = r.Clone(r.Context())
+	if !Accepts(r.Header) {
+		if accept := r.Header.Get("Accept"); accept != "" {
+			r.Header.Set("Accept", accept+", "+ContentType)
+		} else {
+			r.Header.Set("Accept", ContentType+", "+jsonContentType)
+		}
+	}
+	if t.PackRequests {
+		if err := t.packRequest(r); err != nil {
+			return nil, err
+		}
+	}
+	response, responseErr := t.base().RoundTrip(r)
+	if responseErr != nil {
+		return nil, responseErr
+	}
+	if err := t.unpackResponse(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// packRequest 压缩 JSON 请求体, 不是合法的 JSON 或超出大小上限时原样发送
+func (t *Transport) packRequest(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || !_isMediaType(r.Header.Get("Content-Type"), jsonContentType) || r.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, bodyErr := _readBody(r.Body, t.MaxBodySize)
+	if bodyErr == ErrBodyTooLarge {
+		// Stream the large body as it is, with the bytes already read
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil
+	}
+	_ = r.Body.Close()
+	if bodyErr != nil {
+		return bodyErr
+	}
+	if packed, packedErr := _packJSON(body, t.PackOptions); packedErr == nil {
+		body = []byte(packed)
+		r.Header.Set("Content-Type", ContentType)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+// unpackResponse 将压缩的响应体解压为 JSON
+func (t *Transport) unpackResponse(response *http.Response) error {
+	if !_isMediaType(response.Header.Get("Content-Type"), ContentType) || response.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	packed, packedErr := _readBody(response.Body, t.MaxBodySize)
+	_ = response.Body.Close()
+	if packedErr != nil {
+		return packedErr
+	}
+	jsonBytes, jsonErr := _unpackBody(packed, t.MaxBodySize, t.UnpackOptions)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	response.Body = io.NopCloser(bytes.NewReader(jsonBytes))
+	response.ContentLength = int64(len(jsonBytes))
+	response.Header.Set("Content-Type", jsonContentType)
+	response.Header.Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	return nil
+}
@@ -0,0 +1,212 @@
+package gjsonpackhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/JoiLa/gjsonpack"
+)
+
+// A client with Transport reads JSON from a packing server
+func TestTransportResponse(t *testing.T) {
+	var packedResponse string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := httptest.NewRecorder()
+		Handler(http.HandlerFunc(jsonHandler)).ServeHTTP(recorder, r)
+		packedResponse = recorder.Header().Get("Content-Type")
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		_, _ = w.Write(recorder.Body.Bytes())
+	}))
+	defer server.Close()
+	client := NewClient(server.Client().Transport)
+	response, responseErr := client.Get(server.URL)
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	defer response.Body.Close()
+	if packedResponse != ContentType {
+		t.Fatalf("expected a packed response, got %s", packedResponse)
+	}
+	if response.Header.Get("Content-Type") != jsonContentType || response.ContentLength != 31 {
+		t.Fatalf("unexpected response %d %v", response.ContentLength, response.Header)
+	}
+	var planet map[string]string
+	if err := json.NewDecoder(response.Body).Decode(&planet); err != nil || planet["name"] != "earth" {
+		t.Fatalf("unexpected planet %v: %v", planet, err)
+	}
+}
+
+// The Accept header of the request is extended, and the request is not modified
+func TestTransportAccept(t *testing.T) {
+	var accepts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts = append(accepts, r.Header.Get("Accept"))
+	}))
+	defer server.Close()
+	client := NewClient(nil)
+	for _, accept := range []string{"", "text/plain", ContentType} {
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response, responseErr := client.Do(request)
+		if responseErr != nil {
+			t.Fatal(responseErr)
+		}
+		response.Body.Close()
+		if request.Header.Get("Accept") != accept {
+			t.Fatalf("the request was modified: %v", request.Header)
+		}
+	}
+	expected := []string{ContentType + ", application/json", "text/plain, " + ContentType, ContentType}
+	for i := range expected {
+		if accepts[i] != expected[i] {
+			t.Fatalf("unexpected Accept %q, expected %q", accepts[i], expected[i])
+		}
+	}
+}
+
+// JSON request bodies are packed on request
+func TestTransportRequest(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{PackRequests: true}}
+	bodies := map[string]string{
+		"application/json": `{"type":"world"}`,
+		"text/plain":       `{"type":"world"}`,
+	}
+	for contentType, body := range bodies {
+		response, responseErr := client.Post(server.URL, contentType, strings.NewReader(body))
+		if responseErr != nil {
+			t.Fatal(responseErr)
+		}
+		response.Body.Close()
+	}
+	// Invalid JSON is sent as it is
+	response, responseErr := client.Post(server.URL, "application/json", strings.NewReader("{"))
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	response.Body.Close()
+	expected := map[string]bool{
+		ContentType + " type|world^^^$0|1]": true,
+		`text/plain {"type":"world"}`:       true,
+		"application/json {":                true,
+	}
+	for _, request := range received {
+		if !expected[request] {
+			t.Fatalf("unexpected request %s", request)
+		}
+		delete(expected, request)
+	}
+	if len(expected) > 0 {
+		t.Fatalf("missing requests %v", expected)
+	}
+}
+
+// The middleware unpacks the requests of the transport
+func TestTransportMiddleware(t *testing.T) {
+	server := httptest.NewServer(Handler(http.HandlerFunc(jsonHandler)))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{PackRequests: true}}
+	response, responseErr := client.Post(server.URL, "application/json", strings.NewReader(`{"planets":["earth","mars"]}`))
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != `{"planets":["earth","mars"]}` || response.Header.Get("Content-Type") != jsonContentType {
+		t.Fatalf("unexpected response %v %s", response.Header, body)
+	}
+}
+
+// A bad packed response is an error
+func TestTransportBadResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write([]byte("x"))
+	}))
+	defer server.Close()
+	if _, err := NewClient(nil).Get(server.URL); err == nil || !strings.Contains(err.Error(), "gjsonpack") {
+		t.Fatalf("expected an unpack error, got %v", err)
+	}
+}
+
+// Integers beyond 2^53 keep their precision through the transport and the middleware
+func TestTransportLargeIntegers(t *testing.T) {
+	var received string
+	server := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{PackRequests: true}}
+	response, responseErr := client.Post(server.URL, "application/json", strings.NewReader(`{"id":9007199254740993}`))
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if received != `{"id":9007199254740993}` || string(body) != received {
+		t.Fatalf("unexpected request %s and response %s", received, body)
+	}
+}
+
+// Packed responses and their JSON are limited by MaxBodySize, larger requests aren't packed
+func TestTransportMaxBodySize(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type")+" "+string(body))
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write([]byte(r.URL.Query().Get("packed")))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{PackRequests: true, MaxBodySize: 32}}
+	// The packed response of inflated fits, its JSON doesn't
+	inflated := strings.Repeat("a", 10) + "^^^@0|0|0]"
+	for _, packed := range []string{strings.Repeat("a", 40) + "^^^0", inflated} {
+		_, err := client.Get(server.URL + "?packed=" + url.QueryEscape(packed))
+		if !_isTooLarge(err) {
+			t.Fatalf("%s: expected a size error, got %v", packed, err)
+		}
+	}
+	large := `{"text":"` + strings.Repeat("a", 40) + `"}`
+	response, responseErr := client.Post(server.URL+"?packed=a^^^0", "application/json", strings.NewReader(large))
+	if responseErr != nil {
+		t.Fatal(responseErr)
+	}
+	response.Body.Close()
+	if received[len(received)-1] != "application/json "+large {
+		t.Fatalf("unexpected request %s", received[len(received)-1])
+	}
+}
+
+// A small compressed response stops decompressing at MaxBodySize
+func TestTransportDecompressionBomb(t *testing.T) {
+	bomb, _ := gjsonpack.PackWithOptions(strings.Repeat("a", 1<<22), gjsonpack.WithCompression(gjsonpack.CompressionGzip))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write([]byte(bomb))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{MaxBodySize: 8192}}
+	var limitErr *gjsonpack.LimitError
+	if _, err := client.Get(server.URL); !errors.As(err, &limitErr) || limitErr.Limit != "MaxInputSize" {
+		t.Fatalf("expected a MaxInputSize error, got %v", err)
+	}
+}
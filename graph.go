@@ -0,0 +1,121 @@
+package gjsonpack
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CycleError 压缩时检测到循环引用返回的错误
+type CycleError struct {
+	// Path 循环闭合处的路径, 如 "$.children[0].parent"
+	Path string
+	// Type 循环闭合处的值类型
+	Type reflect.Type
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("gjsonpack: encountered a cycle via %s at %s", e.Type, e.Path)
+}
+
+// astAnchor 图模式下共享指针在语法树中的锚点
+type astAnchor struct {
+	// Symbol '$' of the anchored object
+	Symbol string
+	// Referenced is set when the pointer is visited again
+	Referenced bool
+	// Index of the anchor in the packed structure
+	Index int64
+}
+
+// astReference 图模式下对共享指针锚点的引用
+type astReference struct {
+	Anchor *astAnchor
+}
+
+// visitKey 标识一个正在压缩的指针、map 或 slice
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// packState 压缩过程中的状态
+type packState struct {
+	options       *PackOptions
+	dictionaryObj *dictionary
+	// The pointers, maps and slices currently being built
+	visiting map[visitKey]bool
+	// The anchors of the shared pointers in graph mode
+	anchors map[visitKey]*astAnchor
+	// The path of the current item
+	path []string
+	// The bytes added by escaping the strings
+	escapeBytes int64
+	// The statistics, nil unless they are collected
+	stats *statsState
+}
+
+// _newPackState 创建压缩状态
+func _newPackState(dictionaryObj *dictionary, options *PackOptions) *packState {
+	return &packState{
+		options:       options,
+		dictionaryObj: dictionaryObj,
+		visiting:      make(map[visitKey]bool),
+		anchors:       make(map[visitKey]*astAnchor),
+	}
+}
+
+// _visitKey 获取 refItem 的标识, 空值返回 false
+func _visitKey(refItem reflect.Value) (visitKey, bool) {
+	key := visitKey{ptr: refItem.Pointer(), typ: refItem.Type()}
+	if refItem.Kind() == reflect.Slice {
+		key.len = refItem.Len()
+	}
+	return key, key.ptr != 0
+}
+
+// visit 标记 refItem 正在压缩, 再次进入时返回 CycleError
+func (state *packState) visit(refItem reflect.Value) (func(), error) {
+	key, ok := _visitKey(refItem)
+	if !ok {
+		return func() {}, nil
+	}
+	if state.visiting[key] {
+		return nil, &CycleError{Path: "$" + strings.Join(state.path, ""), Type: key.typ}
+	}
+	state.visiting[key] = true
+	return func() {
+		delete(state.visiting, key)
+	}, nil
+}
+
+// anchor 图模式下压缩指针, 再次遇到同一指针时返回对锚点的引用
+func (state *packState) anchor(refItem reflect.Value) (ast, error) {
+	key, _ := _visitKey(refItem)
+	if anchor, anchorExists := state.anchors[key]; anchorExists {
+		state.addReference(anchor)
+		anchor.Referenced = true
+		return astReference{Anchor: anchor}, nil
+	}
+	anchor := &astAnchor{}
+	state.anchors[key] = anchor
+	var inputSize int64
+	if state.stats != nil {
+		inputSize = state.stats.stats.InputSize
+	}
+	builderAst, builderAstErr := recursiveAstBuilder(refItem.Elem().Interface(), state)
+	if builderAstErr != nil {
+		return nil, builderAstErr
+	}
+	if state.stats != nil {
+		state.stats.anchorSizes[anchor] = state.stats.stats.InputSize - inputSize
+	}
+	astObject, astObjectExists := builderAst.([]interface{})
+	if !astObjectExists || len(astObject) == 0 {
+		return builderAst, nil
+	}
+	anchor.Symbol = astObject[0].(string)
+	astObject[0] = anchor
+	return astObject, nil
+}
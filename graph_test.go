@@ -0,0 +1,99 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+type graphNode struct {
+	Name     string       `json:"name"`
+	Parent   *graphNode   `json:"parent,omitempty"`
+	Children []*graphNode `json:"children"`
+}
+
+// Compression of a self referencing structure
+func TestPackCycle(t *testing.T) {
+	root := &graphNode{Name: "root"}
+	child := &graphNode{Name: "child", Parent: root}
+	root.Children = []*graphNode{child}
+	_, packErr := Pack(root)
+	cycleErr, ok := packErr.(*CycleError)
+	if !ok {
+		t.Fatalf("expected a cycle error, got %v", packErr)
+	}
+	if cycleErr.Path != "$.children[0].parent" {
+		t.Fatalf("unexpected cycle path %s", cycleErr.Path)
+	}
+	jsonMap := map[string]interface{}{"name": "loop"}
+	jsonMap["self"] = jsonMap
+	if _, packErr = Pack(jsonMap); packErr == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
+
+// Compression of shared pointers in graph mode
+func TestPackGraph(t *testing.T) {
+	root := &graphNode{Name: "root"}
+	child := &graphNode{Name: "child", Parent: root}
+	root.Children = []*graphNode{child, child}
+	packStr, packErr := PackOptions{Graph: true}.Pack(root)
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	fmt.Println("packStr:", packStr)
+	var unpacked graphNode
+	if err := Unpack(packStr, &unpacked); err != nil {
+		t.Fatal(err)
+	}
+	if len(unpacked.Children) != 2 || unpacked.Children[0] != unpacked.Children[1] {
+		t.Fatal("the shared child isn't rebuilt into one pointer")
+	}
+	if unpacked.Children[0].Name != "child" || unpacked.Children[0].Parent.Children[0] != unpacked.Children[0] {
+		t.Fatal("the cycle isn't rebuilt")
+	}
+	// Shared pointers without a cycle can also be unpacked to JSON
+	shared := &graphNode{Name: "shared"}
+	packStr, packErr = PackOptions{Graph: true}.Pack([]*graphNode{shared, shared})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	jsonStr, unPackErr := UnpackToStr(packStr)
+	if unPackErr != nil {
+		t.Fatal(unPackErr)
+	}
+	if jsonStr != `[{"children":[],"name":"shared"},{"children":[],"name":"shared"}]` {
+		t.Fatalf("unexpected json %s", jsonStr)
+	}
+}
+
+// Numbers that don't fit the destination are errors like in encoding/json
+func TestDecodeNumbers(t *testing.T) {
+	var integer int
+	if err := _decodeValue(float64(42), &integer); err != nil || integer != 42 {
+		t.Fatalf("unexpected integer %d %v", integer, err)
+	}
+	var small int8
+	var unsigned uint
+	var float float32
+	bad := []struct {
+		node interface{}
+		dst  interface{}
+	}{
+		{1.5, &integer},
+		{1e19, &integer},
+		{int64(300), &small},
+		{int64(-1), &unsigned},
+		{-1.0, &unsigned},
+		{1e300, &float},
+	}
+	for _, item := range bad {
+		err := _decodeValue(item.node, item.dst)
+		if _, isTypeErr := err.(*json.UnmarshalTypeError); !isTypeErr {
+			t.Fatalf("%v into %T: expected an UnmarshalTypeError, got %v", item.node, item.dst, err)
+		}
+	}
+	if integer != 42 {
+		t.Fatalf("a bad number modified the integer %d", integer)
+	}
+}
@@ -0,0 +1,154 @@
+package gjsonpack
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// headerPrefix 版本头的前缀, 旧格式的字符串字典中 '%' 之后只会是转义的 2B、7C、5E、25,
+// 因此不会与旧格式混淆
+const headerPrefix = "%GJP"
+
+// FormatVersion 当前压缩格式的版本
+const FormatVersion int64 = 1
+
+// Feature 版本头中记录的格式特性
+type Feature int64
+
+const (
+	// FeatureGraph 结构区包含图模式的锚点与引用
+	FeatureGraph Feature = 1 << iota
+	// FeatureChecksum 数据末尾带有 CRC32 校验
+	FeatureChecksum
+	// FeatureFlate 数据经过 DEFLATE 压缩并以 base64 表示
+	FeatureFlate
+	// FeatureGzip 数据经过 gzip 压缩并以 base64 表示
+	FeatureGzip
+	// FeatureSchema 结构体按字段顺序压缩, 版本头中带有结构的指纹
+	FeatureSchema
+	// FeatureBatch 多个文档共享字典, 结构区之前带有偏移表
+	FeatureBatch
+)
+
+// supportedFeatures 当前版本支持的全部特性
+const supportedFeatures = FeatureGraph | FeatureChecksum | FeatureFlate | FeatureGzip | FeatureSchema | FeatureBatch
+
+// ErrUnsupportedVersion 版本头中的版本高于当前支持的版本
+var ErrUnsupportedVersion = errors.New("gjsonpack: unsupported format version")
+
+// ErrUnsupportedFeature 版本头中包含当前不支持的特性
+var ErrUnsupportedFeature = errors.New("gjsonpack: unsupported format feature")
+
+// ErrChecksumMismatch 数据与末尾的校验不一致, 数据被截断或损坏
+var ErrChecksumMismatch = errors.New("gjsonpack: checksum mismatch")
+
+// packedHeader 版本头信息, 旧格式的 Version 为 0
+type packedHeader struct {
+	Version  int64
+	Features Feature
+	// Schema 结构的指纹, 只在 FeatureSchema 时存在
+	Schema string
+	// SchemaID 结构在 SchemaRegistry 中的版本, 可以不存在
+	SchemaID string
+}
+
+// String 版本头的文本形式, 如 "%GJP1.0^"
+func (h packedHeader) String() string {
+	header := headerPrefix + _baseInt10To36(h.Version) + "." + _baseInt10To36(int64(h.Features))
+	if h.Features&FeatureSchema != 0 {
+		header += "." + h.Schema
+		if h.SchemaID != "" {
+			header += "." + h.SchemaID
+		}
+	}
+	return header + "^"
+}
+
+// _parseHeader 解析 packed 的版本头, 返回版本头及其后的数据, 没有版本头时按旧格式处理
+func _parseHeader(packed string) (packedHeader, string, error) {
+	if !strings.HasPrefix(packed, headerPrefix) {
+		return packedHeader{}, packed, nil
+	}
+	end := strings.Index(packed, "^")
+	if end < 0 {
+		return packedHeader{}, "", errors.New("Bad header, the header isn't terminated! ")
+	}
+	fields := strings.Split(packed[len(headerPrefix):end], ".")
+	if len(fields) < 2 {
+		return packedHeader{}, "", fmt.Errorf("Bad header %s! ", packed[:end])
+	}
+	version, versionErr := _baseString36To10(fields[0])
+	if versionErr != nil {
+		return packedHeader{}, "", fmt.Errorf("Bad header %s! ", packed[:end])
+	}
+	if version < 1 || version > FormatVersion {
+		return packedHeader{}, "", fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+	features, featuresErr := _baseString36To10(fields[1])
+	if featuresErr != nil {
+		return packedHeader{}, "", fmt.Errorf("Bad header %s! ", packed[:end])
+	}
+	if unsupported := Feature(features) &^ supportedFeatures; unsupported != 0 {
+		return packedHeader{}, "", fmt.Errorf("%w %#x", ErrUnsupportedFeature, int64(unsupported))
+	}
+	header := packedHeader{Version: version, Features: Feature(features)}
+	if header.Features&FeatureSchema != 0 {
+		if len(fields) < 3 {
+			return packedHeader{}, "", fmt.Errorf("Bad header %s, the schema is missing! ", packed[:end])
+		}
+		header.Schema = fields[2]
+		if len(fields) > 3 {
+			header.SchemaID = fields[3]
+		}
+	}
+	body := packed[end+1:]
+	if header.Features&FeatureChecksum != 0 {
+		// The checksum covers the header and the body
+		trailer := strings.LastIndex(packed, "^")
+		if trailer <= end || _checksum(packed[:trailer]) != packed[trailer+1:] {
+			return packedHeader{}, "", ErrChecksumMismatch
+		}
+		body = packed[end+1 : trailer]
+	}
+	return header, body, nil
+}
+
+// _wrapPacked 按配置为 packed 加上压缩、版本头与校验, 并转换为输出字母表
+func _wrapPacked(packed string, header packedHeader, options *PackOptions) (string, error) {
+	wrapped, wrappedErr := _wrapHeader(packed, header, options)
+	if wrappedErr != nil || options.Encoding == nil {
+		return wrapped, wrappedErr
+	}
+	return options.Encoding.Encode(wrapped), nil
+}
+
+// _wrapHeader 按配置为 packed 加上压缩、版本头与校验
+func _wrapHeader(packed string, header packedHeader, options *PackOptions) (string, error) {
+	if !options.Header && !options.Checksum && options.Compression == CompressionNone && header.Features&(FeatureSchema|FeatureBatch) == 0 {
+		return packed, nil
+	}
+	header.Version = FormatVersion
+	if options.Compression != CompressionNone {
+		compressed, compressedErr := _compress(packed, options.Compression)
+		if compressedErr != nil {
+			return "", compressedErr
+		}
+		packed = compressed
+		header.Features |= options.Compression.feature()
+	}
+	if options.Checksum {
+		header.Features |= FeatureChecksum
+	}
+	packed = header.String() + packed
+	if options.Checksum {
+		packed += "^" + _checksum(packed)
+	}
+	return packed, nil
+}
+
+// _checksum 计算 packed 的 CRC32 校验
+func _checksum(packed string) string {
+	return _baseInt10To36(int64(crc32.ChecksumIEEE([]byte(packed))))
+}
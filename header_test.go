@@ -0,0 +1,82 @@
+package gjsonpack
+
+import (
+	"errors"
+	"testing"
+)
+
+// Compression with a version header
+func TestPackHeader(t *testing.T) {
+	packStr, packErr := PackWithOptions(map[string]interface{}{"type": "world"}, WithHeader())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "%GJP1.0^type|world^^^$0|1]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	jsonStr, unPackErr := UnpackToStr(packStr)
+	if unPackErr != nil {
+		t.Fatal(unPackErr)
+	}
+	if jsonStr != `{"type":"world"}` {
+		t.Fatalf("unexpected json %s", jsonStr)
+	}
+	// A graph is flagged in the header
+	shared := &graphNode{Name: "shared"}
+	packStr, packErr = PackWithOptions([]*graphNode{shared, shared}, WithHeader(), WithGraph())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr[:8] != "%GJP1.1^" {
+		t.Fatalf("unexpected header %s", packStr)
+	}
+}
+
+// Decompression of legacy and unsupported headers
+func TestUnpackHeader(t *testing.T) {
+	// Legacy strings may start with an escaped '%'
+	jsonStr, unPackErr := UnpackToStr("%25GJP^^^$0|0]")
+	if unPackErr != nil {
+		t.Fatal(unPackErr)
+	}
+	if jsonStr != `{"%GJP":"%GJP"}` {
+		t.Fatalf("unexpected json %s", jsonStr)
+	}
+	if _, unPackErr = UnpackToStr("%GJP2.0^type^^^$0|0]"); !errors.Is(unPackErr, ErrUnsupportedVersion) {
+		t.Fatalf("expected an unsupported version, got %v", unPackErr)
+	}
+	if _, unPackErr = UnpackToStr("%GJP1.100^type^^^$0|0]"); !errors.Is(unPackErr, ErrUnsupportedFeature) {
+		t.Fatalf("expected an unsupported feature, got %v", unPackErr)
+	}
+	if _, unPackErr = UnpackToStr("%GJP1.0"); unPackErr == nil {
+		t.Fatal("expected a bad header")
+	}
+}
+
+// Compression with a checksum trailer
+func TestPackChecksum(t *testing.T) {
+	packStr, packErr := PackWithOptions(map[string]interface{}{"type": "world"}, WithChecksum())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr[:8] != "%GJP1.2^" {
+		t.Fatalf("unexpected header %s", packStr)
+	}
+	jsonStr, unPackErr := UnpackToStr(packStr)
+	if unPackErr != nil {
+		t.Fatal(unPackErr)
+	}
+	if jsonStr != `{"type":"world"}` {
+		t.Fatalf("unexpected json %s", jsonStr)
+	}
+	corrupted := []string{
+		packStr[:len(packStr)-3],
+		packStr[:len(packStr)-10],
+		"%GJP1.2^type|word" + packStr[len("%GJP1.2^type|world"):],
+	}
+	for _, corruptedStr := range corrupted {
+		if _, unPackErr = UnpackToStr(corruptedStr); !errors.Is(unPackErr, ErrChecksumMismatch) {
+			t.Fatalf("%s: expected a checksum mismatch, got %v", corruptedStr, unPackErr)
+		}
+	}
+}
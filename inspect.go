@@ -0,0 +1,92 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Inspection packed 数据的组成, 用于分析压缩效果
+type Inspection struct {
+	// Version 格式版本, 没有版本头时为 0
+	Version int64
+	// Features 版本头中的特性
+	Features Feature
+	// Strings、Integers、Floats 字典中的条目
+	Strings  []DictionaryEntry
+	Integers []DictionaryEntry
+	Floats   []DictionaryEntry
+	// Tokens 结构区的 token 数
+	Tokens int
+	// StringsSize、IntegersSize、FloatsSize、StructureSize 各区的字节数
+	StringsSize   int
+	IntegersSize  int
+	FloatsSize    int
+	StructureSize int
+	// PackedSize packed 字符串的字节数
+	PackedSize int
+	// JSONSize 解压后紧凑 JSON 的字节数, 无法转换为 JSON 时为 -1
+	JSONSize int
+}
+
+// DictionaryEntry 字典中的条目
+type DictionaryEntry struct {
+	// Index 条目在结构区中的索引
+	Index int64
+	// Value 解码后的值: string、int64 或 float64
+	Value interface{}
+	// Refs 结构区中引用该条目的次数, 包括对象的键
+	Refs int
+}
+
+// Ratio packed 与紧凑 JSON 的字节数之比, 无法转换为 JSON 时为 0
+func (i *Inspection) Ratio() float64 {
+	if i.JSONSize <= 0 {
+		return 0
+	}
+	return float64(i.PackedSize) / float64(i.JSONSize)
+}
+
+// Inspect 分析 packed 的字典与结构
+func Inspect(packed string, opts ...UnpackOption) (*Inspection, error) {
+	options := NewUnpackOptions(opts...)
+	state, stateErr := _unpackState(packed, &options)
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	inspection := &Inspection{
+		Version:       state.header.Version,
+		Features:      state.header.Features,
+		Tokens:        len(state.tokenSlice),
+		StringsSize:   len(state.sections[0]),
+		IntegersSize:  len(state.sections[1]),
+		FloatsSize:    len(state.sections[2]),
+		StructureSize: len(state.sections[3]),
+		PackedSize:    len(packed),
+		JSONSize:      -1,
+	}
+	refs := make([]int, len(state.dictionarySlice))
+	for i := 0; i < len(state.tokenSlice); i++ {
+		if state.tokenSlice[i] == "~" {
+			// The next token is an anchor, not a dictionary index
+			i++
+			continue
+		}
+		if index, isIndex := state.tokenSlice[i].(int64); isIndex && index >= 0 && index < int64(len(refs)) {
+			refs[index]++
+		}
+	}
+	var index int64
+	for section, entries := range []*[]DictionaryEntry{&inspection.Strings, &inspection.Integers, &inspection.Floats} {
+		if state.sections[section] == "" {
+			continue
+		}
+		for range strings.Split(state.sections[section], "|") {
+			*entries = append(*entries, DictionaryEntry{Index: index, Value: state.dictionarySlice[index], Refs: refs[index]})
+			index++
+		}
+	}
+	if jsonBytes, jsonMarshalErr := json.Marshal(state.result); jsonMarshalErr == nil {
+		inspection.JSONSize = len(jsonBytes)
+	}
+	return inspection, nil
+}
@@ -0,0 +1,52 @@
+package gjsonpack
+
+import "testing"
+
+// The dictionaries, reference counts and section sizes
+func TestInspect(t *testing.T) {
+	packStr, _ := PackWithOptions(map[string]interface{}{
+		"type": "world",
+		"list": []interface{}{"world", 1, 1, 2.5, true},
+	}, WithSortedKeys())
+	if packStr != "list|world|type^1|1^2.5^$0|@1|3|4|5|-1]|2|1]" {
+		t.Fatalf("unexpected packed %s", packStr)
+	}
+	inspection, inspectErr := Inspect(packStr)
+	if inspectErr != nil {
+		t.Fatal(inspectErr)
+	}
+	if len(inspection.Strings) != 3 || inspection.Strings[1].Value != "world" || inspection.Strings[1].Refs != 2 ||
+		inspection.Strings[2].Refs != 1 {
+		t.Fatalf("unexpected strings %+v", inspection.Strings)
+	}
+	if len(inspection.Integers) != 2 || inspection.Integers[0].Index != 3 || inspection.Integers[0].Value != int64(1) ||
+		inspection.Integers[1].Refs != 1 {
+		t.Fatalf("unexpected integers %+v", inspection.Integers)
+	}
+	if len(inspection.Floats) != 1 || inspection.Floats[0].Index != 5 || inspection.Floats[0].Value != 2.5 {
+		t.Fatalf("unexpected floats %+v", inspection.Floats)
+	}
+	if inspection.Tokens != 12 || inspection.StringsSize != 15 || inspection.IntegersSize != 3 ||
+		inspection.FloatsSize != 3 || inspection.StructureSize != 20 || inspection.PackedSize != len(packStr) {
+		t.Fatalf("unexpected sizes %+v", inspection)
+	}
+	if inspection.JSONSize != len(`{"list":["world",1,1,2.5,true],"type":"world"}`) || inspection.Ratio() <= 0 {
+		t.Fatalf("unexpected JSON size %d", inspection.JSONSize)
+	}
+	// Anchors aren't dictionary references
+	node := &graphNode{Name: "root"}
+	node.Children = []*graphNode{{Name: "child", Parent: node}}
+	graphStr, _ := PackWithOptions(node, WithGraph(), WithHeader())
+	graphInspection, graphInspectErr := Inspect(graphStr)
+	if graphInspectErr != nil {
+		t.Fatal(graphInspectErr)
+	}
+	if graphInspection.Features&FeatureGraph == 0 || graphInspection.JSONSize != -1 || graphInspection.Ratio() != 0 {
+		t.Fatalf("unexpected graph inspection %+v", graphInspection)
+	}
+	for _, packStr := range []string{"x", "0^^^$]1"} {
+		if _, err := Inspect(packStr); err == nil {
+			t.Fatalf("%q: expected an error for bad packed data", packStr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsObject 按 JavaScript 的属性顺序保存的 JSON 对象
+type jsObject []jsMember
+
+// jsMember JSON 对象的成员
+type jsMember struct {
+	Key   string
+	Value interface{}
+}
+
+// _jsCompatValue 兼容模式下将 item 参数转换为 JavaScript 中 JSON.parse 得到的值,
+// string、[]byte、json.RawMessage 作为 JSON 文本解析, 其他值先转换为 JSON
+func _jsCompatValue(item interface{}) (interface{}, error) {
+	var jsonBytes []byte
+	switch jsonText := item.(type) {
+	case string:
+		jsonBytes = []byte(jsonText)
+	case []byte:
+		jsonBytes = jsonText
+	case json.RawMessage:
+		jsonBytes = jsonText
+	default:
+		marshalBytes, marshalErr := json.Marshal(item)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		jsonBytes = marshalBytes
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	value, valueErr := _jsParseValue(decoder)
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("Unexpected data after the JSON value! ")
+	}
+	return value, nil
+}
+
+// _jsParseValue 读取一个 JSON 值, 对象的属性按 JavaScript 的顺序排列
+func _jsParseValue(decoder *json.Decoder) (interface{}, error) {
+	token, tokenErr := decoder.Token()
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '[' {
+			array := make([]interface{}, 0)
+			for decoder.More() {
+				item, itemErr := _jsParseValue(decoder)
+				if itemErr != nil {
+					return nil, itemErr
+				}
+				array = append(array, item)
+			}
+			_, endErr := decoder.Token()
+			return array, endErr
+		}
+		object := make(jsObject, 0)
+		positions := make(map[string]int)
+		for decoder.More() {
+			keyToken, keyErr := decoder.Token()
+			if keyErr != nil {
+				return nil, keyErr
+			}
+			key := keyToken.(string)
+			item, itemErr := _jsParseValue(decoder)
+			if itemErr != nil {
+				return nil, itemErr
+			}
+			// A duplicated key keeps its first position and its last value
+			if position, exists := positions[key]; exists {
+				object[position].Value = item
+				continue
+			}
+			positions[key] = len(object)
+			object = append(object, jsMember{Key: key, Value: item})
+		}
+		if _, endErr := decoder.Token(); endErr != nil {
+			return nil, endErr
+		}
+		// Array index keys come first in ascending order
+		sort.SliceStable(object, func(i, j int) bool {
+			indexI, isIndexI := _jsArrayIndex(object[i].Key)
+			indexJ, isIndexJ := _jsArrayIndex(object[j].Key)
+			if isIndexI && isIndexJ {
+				return indexI < indexJ
+			}
+			return isIndexI && !isIndexJ
+		})
+		return object, nil
+	case json.Number:
+		// All numbers of JavaScript are float64
+		return strconv.ParseFloat(string(value), 64)
+	}
+	return token, nil
+}
+
+// _jsArrayIndex 判断 key 是否是 JavaScript 的数组下标
+func _jsArrayIndex(key string) (uint64, bool) {
+	if key == "" || (len(key) > 1 && key[0] == '0') {
+		return 0, false
+	}
+	index, indexErr := strconv.ParseUint(key, 10, 64)
+	if indexErr != nil || index >= math.MaxUint32 {
+		return 0, false
+	}
+	return index, true
+}
+
+// buildAst 按属性顺序生成对象的语法树
+func (object jsObject) buildAst(state *packState) (ast, error) {
+	state.enterContainer()
+	astObject := make([]interface{}, 0, len(object)*2+1)
+	astObject = append(astObject, "$")
+	for _, member := range object {
+		builderKeyAst, builderKeyAstErr := recursiveAstBuilder(member.Key, state)
+		if builderKeyAstErr != nil {
+			return nil, builderKeyAstErr
+		}
+		astObject = append(astObject, builderKeyAst)
+		state.path = append(state.path, "."+member.Key)
+		builderValueAst, builderValueAstErr := recursiveAstBuilder(member.Value, state)
+		state.path = state.path[:len(state.path)-1]
+		if builderValueAstErr != nil {
+			return nil, builderValueAstErr
+		}
+		astObject = append(astObject, builderValueAst)
+	}
+	state.leaveContainer(astObject)
+	return astObject, nil
+}
+
+// _formatJSNumber 按 JavaScript 中 Number.prototype.toString 的规则格式化浮点数
+func _formatJSNumber(number float64) string {
+	switch {
+	case math.IsNaN(number):
+		return "NaN"
+	case math.IsInf(number, 1):
+		return "Infinity"
+	case math.IsInf(number, -1):
+		return "-Infinity"
+	case number == 0:
+		return "0"
+	}
+	var sign string
+	if number < 0 {
+		sign = "-"
+		number = -number
+	}
+	// The shortest digits and the exponent, as "d.ddde±xx"
+	formatted := strconv.FormatFloat(number, 'e', -1, 64)
+	mantissa, exponentStr := formatted[:strings.IndexByte(formatted, 'e')], formatted[strings.IndexByte(formatted, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exponent, _ := strconv.Atoi(exponentStr)
+	k := len(digits)
+	// The position of the decimal point
+	n := exponent + 1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+	var exponentSign = "+"
+	if n-1 < 0 {
+		exponentSign = "-"
+	}
+	var fraction string
+	if k > 1 {
+		fraction = "." + digits[1:]
+	}
+	return fmt.Sprintf("%s%s%se%s%d", sign, digits[:1], fraction, exponentSign, int(math.Abs(float64(n-1))))
+}
@@ -0,0 +1,68 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// jsonpackVector 由 testdata/jsonpack/record.js 记录的 JavaScript 输出
+type jsonpackVector struct {
+	Name   string `json:"name"`
+	JSON   string `json:"json"`
+	Packed string `json:"packed"`
+}
+
+// jsonpackVectors testdata/jsonpack/vectors.json 的内容
+type jsonpackVectors struct {
+	// JSONPack 记录时 jsonpack 包的版本, 为空时尚未使用 jsonpack 包记录
+	JSONPack string           `json:"jsonpack"`
+	Vectors  []jsonpackVector `json:"vectors"`
+}
+
+// Compression compatible with the JavaScript jsonpack
+func TestPackJSCompat(t *testing.T) {
+	vectorBytes, readErr := os.ReadFile("testdata/jsonpack/vectors.json")
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	var vectors jsonpackVectors
+	if err := json.Unmarshal(vectorBytes, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if vectors.JSONPack == "" {
+		t.Fatal("the vectors aren't recorded with the jsonpack package, run testdata/jsonpack/record.js")
+	}
+	for _, vector := range vectors.Vectors {
+		packStr, packErr := PackWithOptions(vector.JSON, WithJSCompat())
+		if packErr != nil {
+			t.Fatalf("%s: %v", vector.Name, packErr)
+		}
+		if packStr != vector.Packed {
+			t.Fatalf("%s: expected %s, got %s", vector.Name, vector.Packed, packStr)
+		}
+		packStr, packErr = PackWithOptions(json.RawMessage(vector.JSON), WithJSCompat())
+		if packErr != nil || packStr != vector.Packed {
+			t.Fatalf("%s: unexpected raw message result %s %v", vector.Name, packStr, packErr)
+		}
+	}
+}
+
+// Compression of Go values compatible with the JavaScript jsonpack
+func TestPackJSCompatValue(t *testing.T) {
+	type continent struct {
+		Type string  `json:"type"`
+		Name string  `json:"name"`
+		Area float64 `json:"area"`
+	}
+	packStr, packErr := PackWithOptions([]continent{{Type: "continent", Name: "Europe", Area: 10.18}}, WithJSCompat())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "type|continent|name|Europe|area^^10.18^@$0|1|2|3|4|5]]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	if _, packErr = PackWithOptions("{", WithJSCompat()); packErr == nil {
+		t.Fatal("expected a bad JSON")
+	}
+}
@@ -0,0 +1,335 @@
+package gjsonpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// logHeader 记录日志的首行
+const logHeader = "%GJL1"
+
+// 记录日志的行类型
+const (
+	// logCheckpoint 检查点, 之后的记录使用新的字典, 读取可以从任意检查点开始
+	logCheckpoint = 'C'
+	// logDelta 追加到字典的新条目, 格式为 "strings^integers^floats"
+	logDelta = 'D'
+	// logRecord 一条记录的结构区, 索引指向检查点之后的字典
+	logRecord = 'R'
+)
+
+// LogWriter 只追加的记录日志, 每行一条记录, 字典随记录增长,
+// 新的字典条目在引用它的记录之前以增量行写入, 每隔一定数量的记录写入检查点并重置字典:
+//
+//	%GJL1
+//	C
+//	Dtype|world|name|earth^^
+//	R$0|1|2|3]
+//	Dmars^^
+//	R$0|1|2|4]
+type LogWriter struct {
+	w       *bufio.Writer
+	options PackOptions
+	// CheckpointInterval 每隔多少条记录写入检查点, 小于 1 时只在开头写入
+	CheckpointInterval int
+	// The bytes written so far
+	offset int64
+	// The records since the last checkpoint, -1 before the first checkpoint
+	records int
+	// The dictionary since the last checkpoint
+	stringIndexes  map[string]int64
+	integerIndexes map[string]int64
+	floatIndexes   map[float64]int64
+	entries        int64
+}
+
+// NewLogWriter 创建写入 w 的记录日志, opts 为每条记录的压缩选项
+func NewLogWriter(w io.Writer, opts ...PackOption) *LogWriter {
+	return &LogWriter{w: bufio.NewWriter(w), options: NewPackOptions(opts...), records: -1}
+}
+
+// writeLine 写入一行
+func (l *LogWriter) writeLine(kind byte, line string) error {
+	if err := l.w.WriteByte(kind); err != nil {
+		return err
+	}
+	if _, err := l.w.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	l.offset += int64(len(line)) + 2
+	return nil
+}
+
+// Checkpoint 写入检查点并重置字典, 返回检查点在日志中的字节偏移,
+// 从该偏移开始的内容可以由 NewLogReader 单独读取
+func (l *LogWriter) Checkpoint() (int64, error) {
+	if l.offset == 0 {
+		// The header comes first
+		if _, err := l.w.WriteString(logHeader + "\n"); err != nil {
+			return 0, err
+		}
+		l.offset = int64(len(logHeader)) + 1
+	}
+	offset := l.offset
+	if err := l.writeLine(logCheckpoint, ""); err != nil {
+		return 0, err
+	}
+	l.records = 0
+	l.stringIndexes = make(map[string]int64)
+	l.integerIndexes = make(map[string]int64)
+	l.floatIndexes = make(map[float64]int64)
+	l.entries = 0
+	return offset, l.w.Flush()
+}
+
+// Write 写入一条记录
+func (l *LogWriter) Write(v interface{}) error {
+	if l.records < 0 || (l.CheckpointInterval > 0 && l.records >= l.CheckpointInterval) {
+		if _, err := l.Checkpoint(); err != nil {
+			return err
+		}
+	}
+	var dictionaryObj dictionary
+	dictionaryObj.Strings = make(dictionaryString, 0)
+	dictionaryObj.Integers = make(dictionaryIntegers, 0)
+	dictionaryObj.Floats = make(dictionaryFloat, 0)
+	state := _newPackState(&dictionaryObj, &l.options)
+	if l.options.JSCompat {
+		jsValue, jsValueErr := _jsCompatValue(v)
+		if jsValueErr != nil {
+			return jsValueErr
+		}
+		v = jsValue
+	}
+	astTree, astTreeErr := recursiveAstBuilder(v, state)
+	if astTreeErr != nil {
+		return astTreeErr
+	}
+	// Map the entries of the record into the rolling dictionary
+	var deltaStrings, deltaIntegers, deltaFloats []string
+	remap := &dictionaryRemap{
+		strings:  make([]int64, len(dictionaryObj.Strings)),
+		integers: make([]int64, len(dictionaryObj.Integers)),
+		floats:   make([]int64, len(dictionaryObj.Floats)),
+	}
+	for i, str := range dictionaryObj.Strings {
+		index, indexExists := l.stringIndexes[str]
+		if !indexExists {
+			index = l.entries
+			l.entries++
+			l.stringIndexes[str] = index
+			deltaStrings = append(deltaStrings, _encodeLogStr(str))
+		}
+		remap.strings[i] = index
+	}
+	for i, integer := range dictionaryObj.Integers {
+		index, indexExists := l.integerIndexes[integer]
+		if !indexExists {
+			index = l.entries
+			l.entries++
+			l.integerIndexes[integer] = index
+			deltaIntegers = append(deltaIntegers, integer)
+		}
+		remap.integers[i] = index
+	}
+	deltaFloatValues := make(dictionaryFloat, 0)
+	for i, float := range dictionaryObj.Floats {
+		index, indexExists := l.floatIndexes[float]
+		if !indexExists {
+			index = l.entries
+			l.entries++
+			l.floatIndexes[float] = index
+			deltaFloatValues = append(deltaFloatValues, float)
+		}
+		remap.floats[i] = index
+	}
+	if len(deltaFloatValues) > 0 {
+		if l.options.JSCompat {
+			deltaFloats = _arrayFloatToJSArrayString(deltaFloatValues)
+		} else {
+			deltaFloats = _arrayFloatToArrayString(deltaFloatValues)
+		}
+	}
+	if len(deltaStrings)+len(deltaIntegers)+len(deltaFloats) > 0 {
+		delta := strings.Join(deltaStrings, "|") + "^" + strings.Join(deltaIntegers, "|") + "^" + strings.Join(deltaFloats, "|")
+		if err := l.writeLine(logDelta, delta); err != nil {
+			return err
+		}
+	}
+	// The indexes are already global, the rolling dictionary has no sections
+	var anchorIndex int64
+	structure, structureErr := recursiveParser(_logAst(remap.remap(astTree)), 0, 0, 0, &anchorIndex)
+	if structureErr != nil {
+		return structureErr
+	}
+	if err := l.writeLine(logRecord, structure); err != nil {
+		return err
+	}
+	l.records++
+	return nil
+}
+
+// Flush 将缓冲的内容写入底层的 io.Writer, Write 之后需要调用
+func (l *LogWriter) Flush() error {
+	return l.w.Flush()
+}
+
+// _logAst 将合并后的索引都作为字符串索引, recursiveParser 不再加上各字典的偏移
+func _logAst(item ast) ast {
+	switch node := item.(type) {
+	case []interface{}:
+		for i := 1; i < len(node); i++ {
+			node[i] = _logAst(node[i])
+		}
+		return node
+	case astInfo:
+		if node.Type == "integers" || node.Type == "floats" {
+			node.Type = "strings"
+		}
+		return node
+	}
+	return item
+}
+
+// _encodeLogStr 在字典转义之外转义换行, 使每条记录只占一行
+func _encodeLogStr(str string) string {
+	return strings.NewReplacer("\n", "%0A", "\r", "%0D").Replace(str)
+}
+
+// _decodeLogStr 还原 _encodeLogStr 转义的换行
+func _decodeLogStr(str string) string {
+	return strings.NewReplacer("%0A", "\n", "%0D", "\r").Replace(str)
+}
+
+// LogReader 读取 LogWriter 写入的记录日志, 可以从日志开头或任意检查点开始读取
+type LogReader struct {
+	r       *bufio.Reader
+	options UnpackOptions
+	// The dictionary since the last checkpoint, nil before the first checkpoint
+	state *unpackState
+}
+
+// NewLogReader 创建从 r 读取的记录日志, 第一个检查点之前的内容会被跳过
+func NewLogReader(r io.Reader, opts ...UnpackOption) *LogReader {
+	return &LogReader{r: bufio.NewReader(r), options: NewUnpackOptions(opts...)}
+}
+
+// next 读取下一条记录, 日志结束时返回 io.EOF
+func (l *LogReader) next() (*unpackState, error) {
+	for {
+		line, lineErr := l.readLine()
+		if lineErr != nil {
+			return nil, lineErr
+		}
+		if line == "" || line == logHeader {
+			continue
+		}
+		if l.state == nil && line != string(logCheckpoint) {
+			// Skip to the first checkpoint, the reader may start anywhere in the log
+			continue
+		}
+		switch line[0] {
+		case logCheckpoint:
+			if line != string(logCheckpoint) {
+				return nil, fmt.Errorf("Bad log checkpoint %.20q! ", line)
+			}
+			l.state = &unpackState{options: &l.options}
+			continue
+		case logDelta:
+			sections := strings.Split(line[1:], "^")
+			if len(sections) != 3 {
+				return nil, fmt.Errorf("Bad log delta, expected 3 sections but got %d! ", len(sections))
+			}
+			if err := l.state.addDictionaries(_decodeLogStr(sections[0]), sections[1], sections[2]); err != nil {
+				return nil, err
+			}
+			continue
+		case logRecord:
+			// The dictionary is shared, the structure state is per record
+			state := &unpackState{
+				options:         &l.options,
+				dictionarySlice: l.state.dictionarySlice,
+				dictionarySizes: l.state.dictionarySizes,
+			}
+			if err := state.parseStructure(line[1:], 0); err != nil {
+				return nil, err
+			}
+			return state, nil
+		}
+		return nil, fmt.Errorf("Bad log line %.20q! ", line)
+	}
+}
+
+// readLine 读取一行, 受 MaxInputSize 限制
+func (l *LogReader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := l.r.ReadLine()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				break
+			}
+			return "", err
+		}
+		line = append(line, chunk...)
+		if limitErr := _checkLimit("MaxInputSize", l.options.MaxInputSize, int64(len(line))); limitErr != nil {
+			return "", limitErr
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	return string(line), nil
+}
+
+// Next 将下一条记录解压到 v, 日志结束时返回 io.EOF
+func (l *LogReader) Next(v interface{}) error {
+	state, stateErr := l.next()
+	if stateErr != nil {
+		return stateErr
+	}
+	return l.options.decode(state, v)
+}
+
+// NextBytes 解压下一条记录并返回 JSON, 日志结束时返回 io.EOF
+func (l *LogReader) NextBytes() ([]byte, error) {
+	state, stateErr := l.next()
+	if stateErr != nil {
+		return nil, stateErr
+	}
+	return l.options.marshal(state.result)
+}
+
+// ConvertJSONLines 将 JSON Lines 转换为记录日志, 每隔 checkpointInterval 条记录写入检查点,
+// 返回转换的记录数
+func ConvertJSONLines(dst io.Writer, src io.Reader, checkpointInterval int, opts ...PackOption) (int64, error) {
+	writer := NewLogWriter(dst, opts...)
+	writer.CheckpointInterval = checkpointInterval
+	reader := bufio.NewReader(src)
+	var records int64
+	for {
+		line, lineErr := reader.ReadBytes('\n')
+		if lineErr != nil && lineErr != io.EOF {
+			return records, lineErr
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(trimmed))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return records, fmt.Errorf("line %d: %v", records+1, err)
+			}
+			if err := writer.Write(value); err != nil {
+				return records, err
+			}
+			records++
+		}
+		if lineErr == io.EOF {
+			return records, writer.Flush()
+		}
+	}
+}
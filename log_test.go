@@ -0,0 +1,134 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Records share a rolling dictionary with inline deltas
+func TestLogWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewLogWriter(&buffer, WithSortedKeys())
+	for _, record := range []interface{}{
+		map[string]interface{}{"type": "world", "name": "earth"},
+		map[string]interface{}{"type": "world", "name": "mars", "moons": 2},
+		map[string]interface{}{"type": "world", "name": "line\nbreak", "moons": 2.5},
+	} {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "%GJL1\nC\nDname|earth|type|world^^\nR$0|1|2|3]\nDmoons|mars^2^\nR$4|6|0|5|2|3]\nDline%0Abreak^^2.5\nR$4|8|0|7|2|3]\n"
+	if buffer.String() != expected {
+		t.Fatalf("unexpected log %q", buffer.String())
+	}
+	reader := NewLogReader(&buffer)
+	for _, expected := range []string{
+		`{"name":"earth","type":"world"}`,
+		`{"moons":2,"name":"mars","type":"world"}`,
+		`{"moons":2.5,"name":"line\nbreak","type":"world"}`,
+	} {
+		jsonBytes, nextErr := reader.NextBytes()
+		if nextErr != nil {
+			t.Fatal(nextErr)
+		}
+		if string(jsonBytes) != expected {
+			t.Fatalf("unexpected record %s", jsonBytes)
+		}
+	}
+	if _, err := reader.NextBytes(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+// Reading starts from any checkpoint
+func TestLogCheckpoint(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewLogWriter(&buffer)
+	writer.CheckpointInterval = 2
+	for i := 0; i < 3; i++ {
+		if err := writer.Write([]interface{}{"event", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset, checkpointErr := writer.Checkpoint()
+	if checkpointErr != nil {
+		t.Fatal(checkpointErr)
+	}
+	if err := writer.Write([]interface{}{"event", 3}); err != nil {
+		t.Fatal(err)
+	}
+	_ = writer.Flush()
+	if strings.Count(buffer.String(), "\nC\n") != 3 || buffer.String()[offset:offset+2] != "C\n" {
+		t.Fatalf("unexpected checkpoints in %q at %d", buffer.String(), offset)
+	}
+	reader := NewLogReader(bytes.NewReader(buffer.Bytes()[offset:]))
+	var record []interface{}
+	if err := reader.Next(&record); err != nil || record[1] != float64(3) {
+		t.Fatalf("unexpected record %v: %v", record, err)
+	}
+	// Starting in the middle of a segment skips to the next checkpoint
+	second := strings.Index(buffer.String(), "R@0|2]")
+	reader = NewLogReader(strings.NewReader(buffer.String()[second:]))
+	if err := reader.Next(&record); err != nil || record[1] != float64(2) {
+		t.Fatalf("unexpected record %v: %v", record, err)
+	}
+	if _, err := NewLogReader(strings.NewReader("%GJL1\nR@0]\n")).NextBytes(); err != io.EOF {
+		t.Fatalf("expected io.EOF for a record without a checkpoint, got %v", err)
+	}
+	if _, err := NewLogReader(strings.NewReader("C\nX\n")).NextBytes(); err == nil {
+		t.Fatal("expected an error for a bad line")
+	}
+	// Only the exact checkpoint line resets the dictionary
+	fragment := "Cmars^^\nR0\nC\nDearth^^\nR0\n"
+	if jsonBytes, err := NewLogReader(strings.NewReader(fragment)).NextBytes(); err != nil || string(jsonBytes) != `"earth"` {
+		t.Fatalf("unexpected %s: %v", jsonBytes, err)
+	}
+	if _, err := NewLogReader(strings.NewReader("C\nDearth^^\nCx\nR0\n")).NextBytes(); err == nil {
+		t.Fatal("expected an error for a bad checkpoint")
+	}
+}
+
+// JSON Lines are converted into a log
+func TestConvertJSONLines(t *testing.T) {
+	jsonLines := "{\"id\":9007199254740993,\"name\":\"a b\"}\n\n{\"id\":2,\"name\":\"a b\",\"score\":0.5}\n[1,true,null,1e20]"
+	var buffer bytes.Buffer
+	records, convertErr := ConvertJSONLines(&buffer, strings.NewReader(jsonLines), 0, WithSortedKeys())
+	if convertErr != nil || records != 3 {
+		t.Fatalf("converted %d: %v", records, convertErr)
+	}
+	reader := NewLogReader(&buffer, WithUseNumber())
+	var output []string
+	for {
+		jsonBytes, nextErr := reader.NextBytes()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			t.Fatal(nextErr)
+		}
+		output = append(output, string(jsonBytes))
+	}
+	if strings.Join(output, "\n") != "{\"id\":9007199254740993,\"name\":\"a b\"}\n{\"id\":2,\"name\":\"a b\",\"score\":0.5}\n[1,true,null,100000000000000000000]" {
+		t.Fatalf("unexpected output %q", output)
+	}
+	if _, err := ConvertJSONLines(&buffer, strings.NewReader("{\n"), 0); err == nil {
+		t.Fatal("expected an error for bad JSON")
+	}
+}
+
+// A line longer than MaxInputSize is rejected
+func TestLogReaderLimits(t *testing.T) {
+	log := "%GJL1\nC\nD" + strings.Repeat("a", 100) + "^^\nR0\n"
+	if _, err := NewLogReader(strings.NewReader(log), WithMaxInputSize(50)).NextBytes(); err == nil {
+		t.Fatal("expected a limit error")
+	}
+	if jsonBytes, err := NewLogReader(strings.NewReader(log)).NextBytes(); err != nil || len(jsonBytes) != 102 {
+		t.Fatalf("unexpected %s: %v", jsonBytes, err)
+	}
+}
@@ -0,0 +1,90 @@
+package gjsonpack
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// UnsupportedKeyError map 的键类型不能作为 JSON 对象的键
+type UnsupportedKeyError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedKeyError) Error() string {
+	return fmt.Sprintf("gjsonpack: unsupported map key type %s", e.Type)
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// _mapKeyString 与 encoding/json 一致, 将 map 的键转换为字符串:
+// 字符串直接使用, encoding.TextMarshaler 使用 MarshalText, 整数转换为十进制
+func _mapKeyString(refKey reflect.Value) (string, error) {
+	if refKey.Kind() == reflect.String {
+		return refKey.String(), nil
+	}
+	if refKey.Type().Implements(textMarshalerType) {
+		if refKey.Kind() == reflect.Ptr && refKey.IsNil() {
+			return "", nil
+		}
+		keyBytes, keyErr := refKey.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(keyBytes), keyErr
+	}
+	switch refKey.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(refKey.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(refKey.Uint(), 10), nil
+	}
+	return "", &UnsupportedKeyError{Type: refKey.Type()}
+}
+
+// _parseMapKey 与 encoding/json 一致, 将字符串转换为 keyType 类型的键
+func _parseMapKey(key string, keyType reflect.Type) (reflect.Value, error) {
+	if reflect.PtrTo(keyType).Implements(textUnmarshalerType) {
+		refKey := reflect.New(keyType)
+		if err := refKey.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, err
+		}
+		return refKey.Elem(), nil
+	}
+	switch keyType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(keyType), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, numberErr := strconv.ParseInt(key, 10, 64)
+		if numberErr != nil || reflect.Zero(keyType).OverflowInt(number) {
+			return reflect.Value{}, fmt.Errorf("Cannot unpack key %q into %s! ", key, keyType)
+		}
+		return reflect.ValueOf(number).Convert(keyType), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, numberErr := strconv.ParseUint(key, 10, 64)
+		if numberErr != nil || reflect.Zero(keyType).OverflowUint(number) {
+			return reflect.Value{}, fmt.Errorf("Cannot unpack key %q into %s! ", key, keyType)
+		}
+		return reflect.ValueOf(number).Convert(keyType), nil
+	}
+	return reflect.Value{}, &UnsupportedKeyError{Type: keyType}
+}
+
+// mapKeySorter 按转换后的字符串排序 map 的键
+type mapKeySorter struct {
+	refNodeKeys []reflect.Value
+	nodeKeys    []string
+}
+
+func (s mapKeySorter) Len() int {
+	return len(s.nodeKeys)
+}
+
+func (s mapKeySorter) Less(i, j int) bool {
+	return s.nodeKeys[i] < s.nodeKeys[j]
+}
+
+func (s mapKeySorter) Swap(i, j int) {
+	s.refNodeKeys[i], s.refNodeKeys[j] = s.refNodeKeys[j], s.refNodeKeys[i]
+	s.nodeKeys[i], s.nodeKeys[j] = s.nodeKeys[j], s.nodeKeys[i]
+}
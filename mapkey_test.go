@@ -0,0 +1,74 @@
+package gjsonpack
+
+import (
+	"fmt"
+	"testing"
+)
+
+type pointKey struct {
+	X, Y int
+}
+
+func (k pointKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", k.X, k.Y)), nil
+}
+
+func (k *pointKey) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &k.X, &k.Y)
+	return err
+}
+
+// Compression of maps with non-string keys
+func TestMapKeys(t *testing.T) {
+	packStr, packErr := PackWithOptions(map[int]string{10: "ten", 2: "two", -1: "minus one"}, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "-1|minus+one|10|ten|2|two^^^$0|1|2|3|4|5]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	unpacked := make(map[int]string, 0)
+	if err := Unpack(packStr, &unpacked); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked[10] != "ten" || unpacked[-1] != "minus one" {
+		t.Fatalf("unexpected unpacked %v", unpacked)
+	}
+	// The decoder of graph mode and undefined parses the keys too
+	unpackedUint := make(map[uint8]interface{}, 0)
+	packStr, _ = Pack(map[uint8]interface{}{7: Undefined, 8: "eight"})
+	if err := UnpackWithOptions(packStr, &unpackedUint, WithUndefined(UndefinedAsValue)); err != nil {
+		t.Fatal(err)
+	}
+	if unpackedUint[7] != Undefined || unpackedUint[8] != "eight" {
+		t.Fatalf("unexpected unpacked %v", unpackedUint)
+	}
+	if err := UnpackWithOptions(packStr, &map[int8]bool{}, WithUndefined(UndefinedAsValue)); err == nil {
+		t.Fatal("expected a bad value")
+	}
+	// encoding.TextMarshaler keys
+	packStr, packErr = Pack(map[pointKey]int{{X: 1, Y: 2}: 1})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "1,2^1^^$0|1]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	unpackedText := make(map[pointKey]int, 0)
+	if err := UnpackWithOptions(packStr, &unpackedText, WithUndefined(UndefinedAsValue)); err != nil {
+		t.Fatal(err)
+	}
+	if unpackedText[pointKey{X: 1, Y: 2}] != 1 {
+		t.Fatalf("unexpected unpacked %v", unpackedText)
+	}
+	// Other keys are unsupported
+	if _, packErr = Pack(map[float64]int{1.5: 1}); packErr == nil {
+		t.Fatal("expected an unsupported key")
+	} else if _, ok := packErr.(*UnsupportedKeyError); !ok {
+		t.Fatalf("unexpected error %v", packErr)
+	}
+	// Keys must be strings of the dictionary
+	if _, err := UnpackToStr("^1^^$0|0]"); err == nil {
+		t.Fatal("expected a bad key")
+	}
+}
@@ -0,0 +1,308 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// UnpackOptions 解压配置, 零值表示不做任何限制
+type UnpackOptions struct {
+	// MaxInputSize packed 字符串的最大字节数, DEFLATE、gzip 压缩的 packed 解压后同样受此限制
+	MaxInputSize int64
+	// MaxDictionaryEntries 字典(strings、integers、floats)的最大条目总数
+	MaxDictionaryEntries int64
+	// MaxStringLength 单个字典字符串解码后的最大字节数
+	MaxStringLength int64
+	// MaxTokens 结构区的最大 token 数
+	MaxTokens int64
+	// MaxDepth 对象、数组的最大嵌套深度
+	MaxDepth int64
+	// MaxOutputSize 解压后 JSON 的最大字节数
+	MaxOutputSize int64
+	// UseNumber 解压到 interface{} 时数字使用 json.Number 而不是 float64
+	UseNumber bool
+	// Encoding packed 使用的字母表, nil 时为标准格式
+	Encoding *Encoding
+	// Undefined 解压 undefined(-5) 的方式, 默认为 null
+	Undefined UndefinedMode
+}
+
+// LimitError 解压时超出 UnpackOptions 限制返回的错误
+type LimitError struct {
+	// Limit 被超出的限制名称, 如 "MaxDepth"
+	Limit string
+	// Max 限制的值
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("gjsonpack: %s exceeded, the limit is %d", e.Limit, e.Max)
+}
+
+// _checkLimit 检查 actual 是否超出 max, max <= 0 表示不限制
+func _checkLimit(limit string, max, actual int64) error {
+	if max > 0 && actual > max {
+		return &LimitError{Limit: limit, Max: max}
+	}
+	return nil
+}
+
+// Unpack 按配置解压 packed 参数中的数据
+func (o UnpackOptions) Unpack(packed string, v interface{}) error {
+	state, stateErr := _unpackState(packed, &o)
+	if stateErr != nil {
+		return stateErr
+	}
+	return o.decode(state, v)
+}
+
+// decode 将解压状态中的结果写入 v
+func (o UnpackOptions) decode(state *unpackState, v interface{}) error {
+	if unpacker, isUnpacker := v.(Unpacker); isUnpacker && len(state.anchors) == 0 && !o.UseNumber {
+		// Decode with the generated UnpackGJSON method, graph mode rebuilds the shared pointers by reflection
+		return unpacker.UnpackGJSON(state.result)
+	}
+	if len(state.anchors) > 0 || (state.undefined && o.Undefined == UndefinedAsValue) {
+		// Rebuild the shared pointers of graph mode, and keep Undefined
+		decoder := &valueDecoder{pointers: make(map[valueDecoderKey]reflect.Value), useNumber: o.UseNumber}
+		return _decodeValueWith(decoder, state.result, v)
+	}
+	jsonBytes, err := o.marshal(state.result)
+	if err != nil {
+		return err
+	}
+	if o.UseNumber {
+		decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+		decoder.UseNumber()
+		return decoder.Decode(v)
+	}
+	return json.Unmarshal(jsonBytes, v)
+}
+
+// UnpackToStr 按配置解压 packed 参数中的数据并返回字符串
+func (o UnpackOptions) UnpackToStr(packed string) (string, error) {
+	jsonBytes, err := o.UnpackToBytes(packed)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// UnpackToBytes 按配置解压 packed 参数中的数据并返回字节
+func (o UnpackOptions) UnpackToBytes(packed string) ([]byte, error) {
+	unPackerParser, unPackerParserErr := _unpack(packed, &o)
+	if unPackerParserErr != nil {
+		return nil, unPackerParserErr
+	}
+	return o.marshal(unPackerParser)
+}
+
+// marshal 将解压结果转换为 JSON
+func (o UnpackOptions) marshal(unPackerParser interface{}) ([]byte, error) {
+	jsonBytes, jsonMarshalErr := json.Marshal(unPackerParser)
+	if jsonMarshalErr != nil {
+		return nil, jsonMarshalErr
+	}
+	if err := _checkLimit("MaxOutputSize", o.MaxOutputSize, int64(len(jsonBytes))); err != nil {
+		return nil, err
+	}
+	return jsonBytes, nil
+}
+
+// PackOptions 压缩配置, 零值与 Pack 的行为一致
+type PackOptions struct {
+	// Graph 图模式, 指向结构体或 map 的共享指针只压缩一次,
+	// 之后以引用表示, 循环引用也能被压缩
+	Graph bool
+	// SortKeys 按键排序压缩 map, 使相同的数据得到相同的输出
+	SortKeys bool
+	// Header 写入版本头, 记录格式版本与使用的特性
+	Header bool
+	// Checksum 在末尾写入 CRC32 校验, 解压时校验失败返回 ErrChecksumMismatch,
+	// 开启后总是写入版本头
+	Checksum bool
+	// Compression 叠加的第二阶段压缩, 解压时自动识别, 开启后总是写入版本头
+	Compression Compression
+	// Encoding 输出字母表, nil 时使用标准格式
+	Encoding *Encoding
+	// JSCompat 与 JavaScript 的 jsonpack 库兼容, 同一 JSON 的输出与其 pack 逐字节一致:
+	// string、[]byte、json.RawMessage 作为 JSON 文本解析, 其他值先转换为 JSON,
+	// 对象按 JavaScript 的属性顺序压缩, 浮点数按 Number.prototype.toString 格式化,
+	// 绝对值超过 2^53 的整数不保证一致
+	JSCompat bool
+	// Parallelism 顶层为数组时分块并行生成语法树的协程数, 小于 2 时不并行,
+	// 图模式与 JSCompat 不并行; 输出与顺序压缩一致, 开启 SortKeys 时结果是确定的
+	Parallelism int
+}
+
+// Pack 按配置压缩 json 参数中的数据
+func (o PackOptions) Pack(json interface{}) (string, error) {
+	return _pack(json, &o)
+}
+
+// PackOption 压缩选项
+type PackOption func(*PackOptions)
+
+// UnpackOption 解压选项
+type UnpackOption func(*UnpackOptions)
+
+// PackWithOptions 按选项压缩 json 参数中的数据, 不传选项时与 Pack 一致
+func PackWithOptions(json interface{}, opts ...PackOption) (string, error) {
+	return NewPackOptions(opts...).Pack(json)
+}
+
+// UnpackWithOptions 按选项解压 packed 参数中的数据, 不传选项时与 Unpack 一致
+func UnpackWithOptions(packed string, v interface{}, opts ...UnpackOption) error {
+	return NewUnpackOptions(opts...).Unpack(packed, v)
+}
+
+// NewPackOptions 由选项创建可复用的压缩配置
+func NewPackOptions(opts ...PackOption) PackOptions {
+	var o PackOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// NewUnpackOptions 由选项创建可复用的解压配置
+func NewUnpackOptions(opts ...UnpackOption) UnpackOptions {
+	var o UnpackOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithGraph 开启图模式, 见 PackOptions.Graph
+func WithGraph() PackOption {
+	return func(o *PackOptions) {
+		o.Graph = true
+	}
+}
+
+// WithSortedKeys 按键排序压缩 map, 见 PackOptions.SortKeys
+func WithSortedKeys() PackOption {
+	return func(o *PackOptions) {
+		o.SortKeys = true
+	}
+}
+
+// WithHeader 写入版本头, 见 PackOptions.Header
+func WithHeader() PackOption {
+	return func(o *PackOptions) {
+		o.Header = true
+	}
+}
+
+// WithChecksum 写入 CRC32 校验, 见 PackOptions.Checksum
+func WithChecksum() PackOption {
+	return func(o *PackOptions) {
+		o.Checksum = true
+	}
+}
+
+// WithCompression 叠加第二阶段压缩, 见 PackOptions.Compression
+func WithCompression(compression Compression) PackOption {
+	return func(o *PackOptions) {
+		o.Compression = compression
+	}
+}
+
+// WithEncoding 使用 e 作为输出字母表, 见 PackOptions.Encoding
+func WithEncoding(e *Encoding) PackOption {
+	return func(o *PackOptions) {
+		o.Encoding = e
+	}
+}
+
+// WithJSCompat 与 JavaScript 的 jsonpack 库兼容, 见 PackOptions.JSCompat
+func WithJSCompat() PackOption {
+	return func(o *PackOptions) {
+		o.JSCompat = true
+	}
+}
+
+// WithParallelism 使用 n 个协程压缩顶层数组, 见 PackOptions.Parallelism
+func WithParallelism(n int) PackOption {
+	return func(o *PackOptions) {
+		o.Parallelism = n
+	}
+}
+
+// WithLimits 使用 limits 中的全部解压限制
+func WithLimits(limits UnpackOptions) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxInputSize = limits.MaxInputSize
+		o.MaxDictionaryEntries = limits.MaxDictionaryEntries
+		o.MaxStringLength = limits.MaxStringLength
+		o.MaxTokens = limits.MaxTokens
+		o.MaxDepth = limits.MaxDepth
+		o.MaxOutputSize = limits.MaxOutputSize
+	}
+}
+
+// WithMaxInputSize 限制 packed 字符串的最大字节数
+func WithMaxInputSize(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxInputSize = max
+	}
+}
+
+// WithMaxDictionaryEntries 限制字典的最大条目总数
+func WithMaxDictionaryEntries(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxDictionaryEntries = max
+	}
+}
+
+// WithMaxStringLength 限制单个字典字符串的最大字节数
+func WithMaxStringLength(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxStringLength = max
+	}
+}
+
+// WithMaxTokens 限制结构区的最大 token 数
+func WithMaxTokens(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxTokens = max
+	}
+}
+
+// WithMaxDepth 限制对象、数组的最大嵌套深度
+func WithMaxDepth(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxDepth = max
+	}
+}
+
+// WithMaxOutputSize 限制解压后 JSON 的最大字节数
+func WithMaxOutputSize(max int64) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.MaxOutputSize = max
+	}
+}
+
+// WithDecoding 解压字母表为 e 的数据, 见 UnpackOptions.Encoding
+func WithDecoding(e *Encoding) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.Encoding = e
+	}
+}
+
+// WithUndefined 指定解压 undefined 的方式, 见 UnpackOptions.Undefined
+func WithUndefined(mode UndefinedMode) UnpackOption {
+	return func(o *UnpackOptions) {
+		o.Undefined = mode
+	}
+}
+
+// WithUseNumber 解压数字为 json.Number, 见 UnpackOptions.UseNumber
+func WithUseNumber() UnpackOption {
+	return func(o *UnpackOptions) {
+		o.UseNumber = true
+	}
+}
@@ -0,0 +1,38 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Compression and decompression with functional options
+func TestPackWithOptions(t *testing.T) {
+	jsonMap := make(map[string]interface{}, 0)
+	if err := json.Unmarshal([]byte(basicJSON), &jsonMap); err != nil {
+		t.Fatal(err)
+	}
+	packStr, packErr := PackWithOptions(jsonMap, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	expected := "children|name|Antofagasta|type|commune|Chile|country|America|continent|Europe|earth|world^^^$0|@$0|@$0|@$1|2|3|4]]|1|5|3|6]]|1|7|3|8]|$1|9|3|8]]|1|A|3|B]"
+	if packStr != expected {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	for i := 0; i < 10; i++ {
+		if again, _ := PackWithOptions(jsonMap, WithSortedKeys()); again != packStr {
+			t.Fatal("sorted keys aren't deterministic")
+		}
+	}
+	unpacked := make(map[string]interface{}, 0)
+	if err := UnpackWithOptions(packStr, &unpacked, WithMaxDepth(8), WithUseNumber()); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked["name"] != "earth" {
+		t.Fatalf("unexpected unpacked %v", unpacked)
+	}
+	unPackErr := UnpackWithOptions(packStr, &unpacked, WithLimits(UnpackOptions{MaxDepth: 2}))
+	if _, ok := unPackErr.(*LimitError); !ok {
+		t.Fatalf("expected a limit error, got %v", unPackErr)
+	}
+}
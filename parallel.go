@@ -0,0 +1,150 @@
+package gjsonpack
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// parallelChunk 并行压缩中的一块顶层数组
+type parallelChunk struct {
+	state *packState
+	items []interface{}
+	err   error
+}
+
+// _buildParallel 将顶层数组分块并行生成语法树, 再合并各块的字典;
+// 不是数组或元素太少时返回 false, 由调用方顺序压缩
+func _buildParallel(item interface{}, state *packState) (ast, bool, error) {
+	refItem := reflect.ValueOf(item)
+	if refItem.Kind() != reflect.Slice && refItem.Kind() != reflect.Array {
+		return nil, false, nil
+	}
+	itemSliceLen := refItem.Len()
+	chunkLen := state.options.Parallelism
+	if chunkLen > itemSliceLen {
+		chunkLen = itemSliceLen
+	}
+	if chunkLen < 2 {
+		return nil, false, nil
+	}
+	chunks := make([]parallelChunk, chunkLen)
+	var wg sync.WaitGroup
+	for c := range chunks {
+		wg.Add(1)
+		go func(chunk *parallelChunk, start, end int) {
+			defer wg.Done()
+			chunk.state = _newPackState(&dictionary{
+				Strings:  make(dictionaryString, 0),
+				Integers: make(dictionaryIntegers, 0),
+				Floats:   make(dictionaryFloat, 0),
+			}, state.options)
+			if state.stats != nil {
+				// The items are inside the top-level array
+				chunk.state.stats = _newStatsState(&Stats{}, 1)
+			}
+			if refItem.Kind() == reflect.Slice {
+				// The top-level slice is being built, like in the sequential packer
+				if _, err := chunk.state.visit(refItem); err != nil {
+					chunk.err = err
+					return
+				}
+			}
+			for i := start; i < end; i++ {
+				chunk.state.path = append(chunk.state.path[:0], "["+strconv.Itoa(i)+"]")
+				builderArrayAst, builderArrayAstErr := recursiveAstBuilder(refItem.Index(i).Interface(), chunk.state)
+				if builderArrayAstErr != nil {
+					chunk.err = builderArrayAstErr
+					return
+				}
+				chunk.items = append(chunk.items, builderArrayAst)
+			}
+		}(&chunks[c], c*itemSliceLen/chunkLen, (c+1)*itemSliceLen/chunkLen)
+	}
+	wg.Wait()
+	for _, chunk := range chunks {
+		// The first error in element order, like the sequential packer
+		if chunk.err != nil {
+			return nil, true, chunk.err
+		}
+	}
+	state.enterContainer()
+	astArray := make([]interface{}, 1, itemSliceLen+1)
+	astArray[0] = "@"
+	dictionaryObj := state.dictionaryObj
+	for _, chunk := range chunks {
+		remap, escapeBytes := _mergeDictionary(dictionaryObj, chunk.state.dictionaryObj)
+		state.escapeBytes += escapeBytes
+		if state.stats != nil {
+			state.stats.merge(chunk.state.stats)
+		}
+		for _, chunkItem := range chunk.items {
+			astArray = append(astArray, remap.remap(chunkItem))
+		}
+	}
+	state.leaveContainer(astArray)
+	return astArray, true, nil
+}
+
+// dictionaryRemap 一块的字典索引到合并后字典索引的映射
+type dictionaryRemap struct {
+	strings  []int64
+	integers []int64
+	floats   []int64
+}
+
+// _mergeDictionary 按首次出现的顺序将 local 合并到 global, 与顺序压缩的去重规则一致,
+// 同时返回新增字符串条目转义增加的字节数
+func _mergeDictionary(global, local *dictionary) (*dictionaryRemap, int64) {
+	var escapeBytes int64
+	remap := &dictionaryRemap{
+		strings:  make([]int64, len(local.Strings)),
+		integers: make([]int64, len(local.Integers)),
+		floats:   make([]int64, len(local.Floats)),
+	}
+	for i, str := range local.Strings {
+		index := _indexOf(global.Strings, str)
+		if index == -1 {
+			global.Strings = append(global.Strings, str)
+			index = global.Strings.Len() - 1
+			escapeBytes += int64(len(str) - len(_decodeStr(str)))
+		}
+		remap.strings[i] = index
+	}
+	for i, integer := range local.Integers {
+		// Integers are never deduplicated
+		global.Integers = append(global.Integers, integer)
+		remap.integers[i] = global.Integers.Len() - 1
+	}
+	for i, float := range local.Floats {
+		index := _indexOf(global.Floats, float)
+		if index == -1 {
+			global.Floats = append(global.Floats, float)
+			index = global.Floats.Len() - 1
+		}
+		remap.floats[i] = index
+	}
+	return remap, escapeBytes
+}
+
+// remap 将语法树中的字典索引改为合并后的索引
+func (r *dictionaryRemap) remap(item ast) ast {
+	switch node := item.(type) {
+	case []interface{}:
+		for i := 1; i < len(node); i++ {
+			node[i] = r.remap(node[i])
+		}
+		return node
+	case astInfo:
+		switch node.Type {
+		case "strings":
+			node.Index = r.strings[node.Index]
+		case "integers":
+			node.Index = r.integers[node.Index]
+		case "floats":
+			node.Index = r.floats[node.Index]
+		}
+		return node
+	}
+	return item
+}
@@ -0,0 +1,90 @@
+package gjsonpack
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// parallelItems 生成顶层数组, 字符串、整数、浮点数在块之间重复
+func parallelItems(n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"id":    i,
+			"name":  "item " + strconv.Itoa(i%17),
+			"kind":  []string{"a", "b", "c"}[i%3],
+			"price": float64(i%7) + 0.5,
+			"tags":  []interface{}{"x|" + strconv.Itoa(i%5), i%2 == 0, nil, ""},
+		}
+	}
+	return items
+}
+
+// The parallel packer is identical to the sequential packer
+func TestPackParallel(t *testing.T) {
+	items := parallelItems(1000)
+	sequentialStr, sequentialErr := PackWithOptions(items, WithSortedKeys())
+	if sequentialErr != nil {
+		t.Fatal(sequentialErr)
+	}
+	for _, parallelism := range []int{2, 3, 8, 1000, 5000} {
+		parallelStr, parallelErr := PackWithOptions(items, WithSortedKeys(), WithParallelism(parallelism))
+		if parallelErr != nil {
+			t.Fatal(parallelErr)
+		}
+		if parallelStr != sequentialStr {
+			t.Fatalf("parallelism %d differs from the sequential packer", parallelism)
+		}
+	}
+	_, sequentialStats, _ := PackWithStats(items, WithSortedKeys())
+	_, parallelStats, _ := PackWithStats(items, WithSortedKeys(), WithParallelism(4))
+	if *parallelStats != *sequentialStats {
+		t.Fatalf("parallel stats %+v, sequential %+v", parallelStats, sequentialStats)
+	}
+	// Arrays and scalars
+	arrayStr, _ := PackWithOptions([4]interface{}{"a", 1, 1.5, "a"}, WithParallelism(2))
+	if arrayStr != "a^1^1.5^@0|1|2|0]" {
+		t.Fatalf("unexpected packed %s", arrayStr)
+	}
+	scalarStr, _ := PackWithOptions("a", WithParallelism(2))
+	if scalarStr != "a^^^0" {
+		t.Fatalf("unexpected packed %s", scalarStr)
+	}
+}
+
+// A raw string equal to the escaped form of another string keeps its own entry
+func TestPackParallelEscaped(t *testing.T) {
+	for _, items := range [][]interface{}{
+		{"a b", "x", "a+b", "y"},
+		{"a+b", "a b", "a+b", "a b"},
+		{"a b", "a+b"},
+	} {
+		sequentialStr, _ := PackWithOptions(items)
+		parallelStr, _ := PackWithOptions(items, WithParallelism(2))
+		if parallelStr != sequentialStr {
+			t.Fatalf("%q: parallel %s, sequential %s", items, parallelStr, sequentialStr)
+		}
+		var unpacked []interface{}
+		if err := Unpack(parallelStr, &unpacked); err != nil || !reflect.DeepEqual(unpacked, items) {
+			t.Fatalf("%q: unexpected %q: %v", items, unpacked, err)
+		}
+	}
+}
+
+// Errors are reported for the first element, cycles through the top-level slice are detected
+func TestPackParallelErrors(t *testing.T) {
+	items := []interface{}{1, 2, make(chan int), 4, func() {}}
+	_, sequentialErr := PackWithOptions(items)
+	_, parallelErr := PackWithOptions(items, WithParallelism(3))
+	if parallelErr == nil || parallelErr.Error() != sequentialErr.Error() {
+		t.Fatalf("parallel %v, sequential %v", parallelErr, sequentialErr)
+	}
+	cyclic := []interface{}{1, nil}
+	cyclic[1] = cyclic
+	var cycleErr *CycleError
+	if _, err := PackWithOptions(cyclic, WithParallelism(2)); !errors.As(err, &cycleErr) || cycleErr.Path != "$[1]" {
+		t.Fatalf("expected a cycle at $[1], got %v", err)
+	}
+}
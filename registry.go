@@ -0,0 +1,210 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SchemaRegistry 结构体各版本的结构, 按版本压缩并把旧版本的数据解压到当前的结构体:
+// 新增的字段为零值或 gjsonpack 标签中的默认值, 删除的字段被跳过,
+// 改名的字段通过 gjsonpack 标签中的别名找到旧名称, 如
+//
+//	Title string `json:"title" gjsonpack:"alias=name,default=untitled"`
+type SchemaRegistry struct {
+	mutex    sync.RWMutex
+	versions map[uint64]*Schema
+	types    map[reflect.Type]uint64
+}
+
+// NewSchemaRegistry 创建结构注册表
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		versions: make(map[uint64]*Schema),
+		types:    make(map[reflect.Type]uint64),
+	}
+}
+
+// Register 注册结构体 v 的类型为版本 id, 同一版本与同一类型都只能注册一次,
+// 旧版本需要使用各自的结构体类型, Pack 按类型选择版本
+func (r *SchemaRegistry) Register(id uint64, v interface{}) error {
+	schema, schemaErr := SchemaOf(v)
+	if schemaErr != nil {
+		return schemaErr
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, versionExists := r.versions[id]; versionExists {
+		return fmt.Errorf("The schema %d is already registered! ", id)
+	}
+	if registeredID, typeExists := r.types[schema.root.Type]; typeExists {
+		return fmt.Errorf("The type %v is already registered as the schema %d! ", schema.root.Type, registeredID)
+	}
+	r.versions[id] = schema
+	r.types[schema.root.Type] = id
+	return nil
+}
+
+// Pack 按 v 的类型注册的版本压缩结构体 v, 版本写入版本头
+func (r *SchemaRegistry) Pack(v interface{}, opts ...PackOption) (string, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	r.mutex.RLock()
+	id, idExists := r.types[t]
+	schema := r.versions[id]
+	r.mutex.RUnlock()
+	if !idExists {
+		return "", fmt.Errorf("The type %v isn't registered! ", t)
+	}
+	return schema.pack(v, NewPackOptions(opts...), packedHeader{Schema: schema.fingerprint, SchemaID: _baseInt10To36(int64(id))})
+}
+
+// Unpack 按版本头中的版本解压 packed, 并转换为结构体 v 的当前结构,
+// 没有版本的数据按 Pack 压缩的对象处理
+func (r *SchemaRegistry) Unpack(packed string, v interface{}, opts ...UnpackOption) error {
+	current, currentErr := SchemaOf(v)
+	if currentErr != nil {
+		return currentErr
+	}
+	options := NewUnpackOptions(opts...)
+	state, stateErr := _unpackState(packed, &options)
+	if stateErr != nil {
+		return stateErr
+	}
+	var keyed = state.result
+	if state.header.Features&FeatureSchema != 0 {
+		if state.header.SchemaID == "" {
+			// Packed by PackStruct
+			if state.header.Schema != current.fingerprint {
+				return fmt.Errorf("%w, the data has no schema version", ErrSchemaMismatch)
+			}
+			return current.decode(state.result, v)
+		}
+		id, idErr := _baseString36To10(state.header.SchemaID)
+		if idErr != nil {
+			return fmt.Errorf("Bad schema version %s! ", state.header.SchemaID)
+		}
+		r.mutex.RLock()
+		schema, schemaExists := r.versions[uint64(id)]
+		r.mutex.RUnlock()
+		if !schemaExists {
+			return fmt.Errorf("%w, the schema version %d isn't registered", ErrSchemaMismatch, id)
+		}
+		if schema.fingerprint != state.header.Schema {
+			return fmt.Errorf("%w, the schema version %d is registered as %s but the data is %s", ErrSchemaMismatch, id, schema.fingerprint, state.header.Schema)
+		}
+		var keyedErr error
+		if keyed, keyedErr = schema.root.keyed(state.result); keyedErr != nil {
+			return keyedErr
+		}
+	}
+	evolved, evolvedErr := current.root.evolve(keyed)
+	if evolvedErr != nil {
+		return evolvedErr
+	}
+	return _decodeValue(evolved, v)
+}
+
+// evolve 将旧版本的对象转换为当前结构: 通过别名找到改名的字段, 缺少的字段使用默认值
+func (node *schemaNode) evolve(value interface{}) (interface{}, error) {
+	switch node.Kind {
+	case schemaStruct:
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return value, nil
+		}
+		evolved := make(map[string]interface{}, len(node.Fields))
+		for _, field := range node.Fields {
+			fieldValue, fieldValueExists := object[field.Name]
+			for i := 0; !fieldValueExists && i < len(field.Aliases); i++ {
+				fieldValue, fieldValueExists = object[field.Aliases[i]]
+			}
+			if !fieldValueExists {
+				if field.Default == nil {
+					continue
+				}
+				defaultValue, defaultValueErr := field.defaultValue()
+				if defaultValueErr != nil {
+					return nil, defaultValueErr
+				}
+				evolved[field.Name] = defaultValue
+				continue
+			}
+			evolvedValue, evolvedValueErr := field.Node.evolve(fieldValue)
+			if evolvedValueErr != nil {
+				return nil, evolvedValueErr
+			}
+			evolved[field.Name] = evolvedValue
+		}
+		return evolved, nil
+	case schemaList:
+		values, isArray := value.([]interface{})
+		if !isArray {
+			return value, nil
+		}
+		for i := range values {
+			evolvedValue, evolvedValueErr := node.Elem.evolve(values[i])
+			if evolvedValueErr != nil {
+				return nil, evolvedValueErr
+			}
+			values[i] = evolvedValue
+		}
+		return values, nil
+	case schemaMap:
+		values, isObject := value.(map[string]interface{})
+		if !isObject {
+			return value, nil
+		}
+		for key, itemValue := range values {
+			evolvedValue, evolvedValueErr := node.Elem.evolve(itemValue)
+			if evolvedValueErr != nil {
+				return nil, evolvedValueErr
+			}
+			values[key] = evolvedValue
+		}
+		return values, nil
+	}
+	return value, nil
+}
+
+// defaultValue 字段的默认值, 字符串字段直接使用标签中的文本, 其他字段按 JSON 解析
+func (field schemaField) defaultValue() (interface{}, error) {
+	if field.Node.Kind == schemaValue && field.Node.Type.Kind() == reflect.String {
+		return *field.Default, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(*field.Default), &value); err != nil {
+		return nil, fmt.Errorf("Bad default %q of the field %s: %v! ", *field.Default, field.Name, err)
+	}
+	return value, nil
+}
+
+// _parseSchemaTag 解析 gjsonpack 标签, 如 "alias=name,alias=title,default=untitled",
+// 默认值可以包含逗号, 如 "default=[1,2]", 只有后接 "alias=" 或 "default=" 的逗号分隔选项
+func _parseSchemaTag(tag string) ([]string, *string) {
+	var aliases []string
+	var defaultValue *string
+	var options []string
+	for _, part := range strings.Split(tag, ",") {
+		if len(options) > 0 && !strings.HasPrefix(part, "alias=") && !strings.HasPrefix(part, "default=") {
+			// The comma belongs to the previous value
+			options[len(options)-1] += "," + part
+			continue
+		}
+		options = append(options, part)
+	}
+	for _, option := range options {
+		switch {
+		case strings.HasPrefix(option, "alias="):
+			aliases = append(aliases, option[len("alias="):])
+		case strings.HasPrefix(option, "default="):
+			value := option[len("default="):]
+			defaultValue = &value
+		}
+	}
+	return aliases, defaultValue
+}
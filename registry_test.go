@@ -0,0 +1,116 @@
+package gjsonpack
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type registryArticleV1 struct {
+	Name   string   `json:"name"`
+	Body   string   `json:"body"`
+	Legacy int      `json:"legacy"`
+	Tags   []string `json:"tags"`
+}
+
+type registryArticleV2 struct {
+	Title    string   `json:"title" gjsonpack:"alias=name"`
+	Body     string   `json:"body"`
+	Tags     []string `json:"tags"`
+	Views    int      `json:"views"`
+	Language string   `json:"language" gjsonpack:"default=en"`
+	Score    float64  `json:"score" gjsonpack:"default=1.5"`
+}
+
+// Old versions are decoded into the current struct
+func TestSchemaRegistry(t *testing.T) {
+	registry := NewSchemaRegistry()
+	if err := registry.Register(1, registryArticleV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(2, registryArticleV2{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(2, registryArticleV1{}); err == nil {
+		t.Fatal("expected an error for a registered version")
+	}
+	packStr, packErr := registry.Pack(&registryArticleV1{Name: "gjsonpack", Body: "hello world", Legacy: 7, Tags: []string{"go"}})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	var article registryArticleV2
+	if err := registry.Unpack(packStr, &article); err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "gjsonpack" || article.Body != "hello world" || len(article.Tags) != 1 ||
+		article.Views != 0 || article.Language != "en" || article.Score != 1.5 {
+		t.Fatalf("unexpected unpacked %+v", article)
+	}
+	// The current version keeps its own values
+	packStr, packErr = registry.Pack(registryArticleV2{Title: "current", Language: "zh"})
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	article = registryArticleV2{}
+	if err := registry.Unpack(packStr, &article); err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "current" || article.Language != "zh" || article.Score != 0 {
+		t.Fatalf("unexpected unpacked %+v", article)
+	}
+	// Keyed data without a version is decoded by field names
+	keyedStr, _ := Pack(map[string]interface{}{"name": "keyed", "views": 3})
+	article = registryArticleV2{}
+	if err := registry.Unpack(keyedStr, &article); err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "keyed" || article.Views != 3 || article.Language != "en" {
+		t.Fatalf("unexpected unpacked %+v", article)
+	}
+}
+
+// Unknown versions and unregistered types are rejected
+func TestSchemaRegistryErrors(t *testing.T) {
+	registry := NewSchemaRegistry()
+	if _, err := registry.Pack(registryArticleV1{}); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+	other := NewSchemaRegistry()
+	_ = other.Register(9, registryArticleV1{})
+	packStr, _ := other.Pack(registryArticleV1{Name: "other"})
+	_ = registry.Register(1, registryArticleV2{})
+	var article registryArticleV2
+	if err := registry.Unpack(packStr, &article); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected a schema mismatch, got %v", err)
+	}
+	// A type is registered once
+	if err := registry.Register(2, registryArticleV2{}); err == nil {
+		t.Fatal("expected an error for a registered type")
+	}
+	// The same version with another layout
+	third := NewSchemaRegistry()
+	_ = third.Register(9, registryArticleV2{})
+	if err := third.Unpack(packStr, &article); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected a schema mismatch, got %v", err)
+	}
+}
+
+// Defaults may contain commas
+func TestParseSchemaTag(t *testing.T) {
+	tags := map[string][2]string{
+		"alias=name,default=a,b":           {"name", "a,b"},
+		"default=[1,2],alias=name":         {"name", "[1,2]"},
+		"alias=name,alias=title,default=x": {"name title", "x"},
+		"alias=name":                       {"name", "<nil>"},
+	}
+	for tag, expected := range tags {
+		aliases, defaultValue := _parseSchemaTag(tag)
+		actualDefault := "<nil>"
+		if defaultValue != nil {
+			actualDefault = *defaultValue
+		}
+		if strings.Join(aliases, " ") != expected[0] || actualDefault != expected[1] {
+			t.Fatalf("%s: unexpected aliases %v and default %s", tag, aliases, actualDefault)
+		}
+	}
+}
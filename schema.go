@@ -0,0 +1,359 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrSchemaMismatch 数据的结构与解压目标的结构不一致
+var ErrSchemaMismatch = errors.New("gjsonpack: schema mismatch")
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+var undefinedType = reflect.TypeOf(Undefined)
+
+// Schema 由 Go 结构体类型推导出的字段结构, 按字段顺序压缩时只写入字段的值
+type Schema struct {
+	root        *schemaNode
+	fingerprint string
+}
+
+// schemaKind 结构节点的类型
+type schemaKind int
+
+const (
+	// schemaValue 按原样压缩的值
+	schemaValue schemaKind = iota
+	// schemaMarshaler 通过 json.Marshaler 或 encoding.TextMarshaler 转换后压缩的值
+	schemaMarshaler
+	// schemaStruct 按字段顺序压缩为数组的结构体
+	schemaStruct
+	// schemaList slice 或数组
+	schemaList
+	// schemaMap map
+	schemaMap
+)
+
+// schemaNode 结构中的一个类型
+type schemaNode struct {
+	Kind   schemaKind
+	Type   reflect.Type
+	Fields []schemaField
+	Elem   *schemaNode
+}
+
+// schemaField 结构体的字段
+type schemaField struct {
+	Name  string
+	Index int
+	Node  *schemaNode
+	// Aliases 旧版本结构中字段的名称, 来自 gjsonpack 标签的 alias
+	Aliases []string
+	// Default 旧版本结构中没有该字段时的默认值, 来自 gjsonpack 标签的 default
+	Default *string
+}
+
+// SchemaOf 推导 v 的结构, v 必须是结构体或指向结构体的指针
+func SchemaOf(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Unexpected argument of type %v, a struct is required! ", reflect.TypeOf(v))
+	}
+	return _schemaOfType(t), nil
+}
+
+// _schemaOfType 推导结构体类型 t 的结构
+func _schemaOfType(t reflect.Type) *Schema {
+	root := _schemaNode(t, make(map[reflect.Type]*schemaNode))
+	var description strings.Builder
+	root.describe(&description, make(map[*schemaNode]int))
+	return &Schema{root: root, fingerprint: _baseInt10To36(int64(crc32.ChecksumIEEE([]byte(description.String()))))}
+}
+
+// Fingerprint 结构的指纹, 字段的名称、顺序与类型任一变化都会改变指纹
+func (s *Schema) Fingerprint() string {
+	return s.fingerprint
+}
+
+// _schemaNode 推导类型 t 的结构节点, cache 保证递归类型只推导一次
+func _schemaNode(t reflect.Type, cache map[reflect.Type]*schemaNode) *schemaNode {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node, nodeExists := cache[t]; nodeExists {
+		return node
+	}
+	node := &schemaNode{Kind: schemaValue, Type: t}
+	cache[t] = node
+	pt := reflect.PtrTo(t)
+	if t == undefinedType {
+		return node
+	}
+	if t.Implements(jsonMarshalerType) || pt.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) || pt.Implements(textMarshalerType) {
+		node.Kind = schemaMarshaler
+		return node
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		node.Kind = schemaStruct
+		for _, field := range _structFields(t) {
+			aliases, defaultValue := _parseSchemaTag(t.Field(field.Index).Tag.Get("gjsonpack"))
+			node.Fields = append(node.Fields, schemaField{
+				Name:    field.Name,
+				Index:   field.Index,
+				Node:    _schemaNode(t.Field(field.Index).Type, cache),
+				Aliases: aliases,
+				Default: defaultValue,
+			})
+		}
+	case reflect.Slice, reflect.Array:
+		node.Kind = schemaList
+		node.Elem = _schemaNode(t.Elem(), cache)
+	case reflect.Map:
+		node.Kind = schemaMap
+		node.Elem = _schemaNode(t.Elem(), cache)
+	}
+	return node
+}
+
+// describe 写入结构节点的描述, 用于计算指纹
+func (node *schemaNode) describe(b *strings.Builder, seen map[*schemaNode]int) {
+	switch node.Kind {
+	case schemaStruct:
+		if index, indexExists := seen[node]; indexExists {
+			// A recursive type refers to its first description
+			b.WriteString("#" + strconv.Itoa(index))
+			return
+		}
+		seen[node] = len(seen)
+		b.WriteString("{")
+		for i, field := range node.Fields {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(strconv.Quote(field.Name) + ":")
+			field.Node.describe(b, seen)
+		}
+		b.WriteString("}")
+	case schemaList:
+		b.WriteString("[")
+		node.Elem.describe(b, seen)
+		b.WriteString("]")
+	case schemaMap:
+		// The key type changes how the keys are written, as in map[string]T and map[int]T
+		keyType := node.Type.Key()
+		b.WriteString("<")
+		if keyType.Kind() != reflect.String && (keyType.Implements(textMarshalerType) || reflect.PtrTo(keyType).Implements(textMarshalerType)) {
+			b.WriteString("text")
+		} else {
+			b.WriteString(_describeKind(keyType.Kind()))
+		}
+		b.WriteString(":")
+		node.Elem.describe(b, seen)
+		b.WriteString(">")
+	case schemaMarshaler:
+		b.WriteString("json")
+	default:
+		b.WriteString(_describeKind(node.Type.Kind()))
+	}
+}
+
+// _describeKind 基本类型在结构描述中的写法
+func _describeKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "s"
+	case reflect.Bool:
+		return "b"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "i"
+	case reflect.Float32, reflect.Float64:
+		return "f"
+	case reflect.Interface:
+		return "a"
+	}
+	return kind.String()
+}
+
+// positional 按结构将 refValue 转换为按字段顺序排列的值
+func (node *schemaNode) positional(refValue reflect.Value, state *packState) (interface{}, error) {
+	for refValue.Kind() == reflect.Ptr || (refValue.Kind() == reflect.Interface && node.Kind != schemaValue) {
+		if refValue.IsNil() {
+			return nil, nil
+		}
+		if refValue.Kind() == reflect.Ptr {
+			leave, visitErr := state.visit(refValue)
+			if visitErr != nil {
+				return nil, visitErr
+			}
+			defer leave()
+		}
+		refValue = refValue.Elem()
+	}
+	if !refValue.IsValid() {
+		return nil, nil
+	}
+	switch node.Kind {
+	case schemaMarshaler:
+		jsonBytes, jsonMarshalErr := json.Marshal(refValue.Interface())
+		if jsonMarshalErr != nil {
+			return nil, jsonMarshalErr
+		}
+		var value interface{}
+		return value, json.Unmarshal(jsonBytes, &value)
+	case schemaStruct:
+		values := make([]interface{}, len(node.Fields))
+		for i, field := range node.Fields {
+			state.path = append(state.path, "."+field.Name)
+			value, valueErr := field.Node.positional(refValue.Field(field.Index), state)
+			state.path = state.path[:len(state.path)-1]
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			values[i] = value
+		}
+		return values, nil
+	case schemaList:
+		values := make([]interface{}, refValue.Len())
+		for i := range values {
+			state.path = append(state.path, "["+strconv.Itoa(i)+"]")
+			value, valueErr := node.Elem.positional(refValue.Index(i), state)
+			state.path = state.path[:len(state.path)-1]
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			values[i] = value
+		}
+		return values, nil
+	case schemaMap:
+		values := make(map[string]interface{}, refValue.Len())
+		for _, refKey := range refValue.MapKeys() {
+			key, keyErr := _mapKeyString(refKey)
+			if keyErr != nil {
+				return nil, keyErr
+			}
+			state.path = append(state.path, "."+key)
+			value, valueErr := node.Elem.positional(refValue.MapIndex(refKey), state)
+			state.path = state.path[:len(state.path)-1]
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+	return refValue.Interface(), nil
+}
+
+// keyed 按结构将按字段顺序排列的解压结果转换为以字段名为键的对象
+func (node *schemaNode) keyed(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch node.Kind {
+	case schemaStruct:
+		values, isArray := value.([]interface{})
+		if !isArray || len(values) != len(node.Fields) {
+			return nil, fmt.Errorf("%w, %s expects %d fields", ErrSchemaMismatch, node.Type, len(node.Fields))
+		}
+		object := make(map[string]interface{}, len(values))
+		for i, field := range node.Fields {
+			fieldValue, fieldValueErr := field.Node.keyed(values[i])
+			if fieldValueErr != nil {
+				return nil, fieldValueErr
+			}
+			object[field.Name] = fieldValue
+		}
+		return object, nil
+	case schemaList:
+		values, isArray := value.([]interface{})
+		if !isArray {
+			return value, nil
+		}
+		for i := range values {
+			itemValue, itemValueErr := node.Elem.keyed(values[i])
+			if itemValueErr != nil {
+				return nil, itemValueErr
+			}
+			values[i] = itemValue
+		}
+		return values, nil
+	case schemaMap:
+		values, isObject := value.(map[string]interface{})
+		if !isObject {
+			return value, nil
+		}
+		for key, itemValue := range values {
+			keyedValue, keyedValueErr := node.Elem.keyed(itemValue)
+			if keyedValueErr != nil {
+				return nil, keyedValueErr
+			}
+			values[key] = keyedValue
+		}
+		return values, nil
+	}
+	return value, nil
+}
+
+// PackStruct 按字段顺序压缩结构体 v, 只写入字段的值与结构的指纹, 不写入字段名
+func PackStruct(v interface{}, opts ...PackOption) (string, error) {
+	schema, schemaErr := SchemaOf(v)
+	if schemaErr != nil {
+		return "", schemaErr
+	}
+	return schema.pack(v, NewPackOptions(opts...), packedHeader{Schema: schema.fingerprint})
+}
+
+// pack 按结构压缩 v, header 中的结构标识写入版本头
+func (s *Schema) pack(v interface{}, options PackOptions, header packedHeader) (string, error) {
+	// The positional values have no shared pointers
+	options.Graph = false
+	positional, positionalErr := s.root.positional(reflect.ValueOf(v), _newPackState(nil, &options))
+	if positionalErr != nil {
+		return "", positionalErr
+	}
+	packedBody, features, packedBodyErr := _packBody(positional, &options)
+	if packedBodyErr != nil {
+		return "", packedBodyErr
+	}
+	header.Features = features | FeatureSchema
+	return _wrapPacked(packedBody, header, &options)
+}
+
+// UnpackStruct 解压 PackStruct 压缩的数据到结构体 v, v 的结构指纹必须与数据一致
+func UnpackStruct(packed string, v interface{}, opts ...UnpackOption) error {
+	schema, schemaErr := SchemaOf(v)
+	if schemaErr != nil {
+		return schemaErr
+	}
+	options := NewUnpackOptions(opts...)
+	state, stateErr := _unpackState(packed, &options)
+	if stateErr != nil {
+		return stateErr
+	}
+	if state.header.Features&FeatureSchema == 0 {
+		return fmt.Errorf("%w, the data isn't packed by PackStruct", ErrSchemaMismatch)
+	}
+	if state.header.Schema != schema.fingerprint {
+		return fmt.Errorf("%w, the data is packed from schema %s but %s is %s", ErrSchemaMismatch, state.header.Schema, reflect.TypeOf(v), schema.fingerprint)
+	}
+	return schema.decode(state.result, v)
+}
+
+// decode 将按结构 s 排列的解压结果写入 v
+func (s *Schema) decode(value interface{}, v interface{}) error {
+	keyed, keyedErr := s.root.keyed(value)
+	if keyedErr != nil {
+		return keyedErr
+	}
+	return _decodeValue(keyed, v)
+}
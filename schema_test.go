@@ -0,0 +1,121 @@
+package gjsonpack
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type schemaCountry struct {
+	Name    string            `json:"name"`
+	Capital *schemaCity       `json:"capital"`
+	Cities  []schemaCity      `json:"cities"`
+	Codes   map[string]int    `json:"codes"`
+	Founded time.Time         `json:"founded"`
+	Regions []*schemaCountry  `json:"regions"`
+	Extra   interface{}       `json:"extra"`
+	Ignored string            `json:"-"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	private int
+}
+
+type schemaCity struct {
+	Name       string  `json:"name"`
+	Population int     `json:"population"`
+	Latitude   float64 `json:"latitude"`
+}
+
+// Positional compression of Go structs
+func TestPackStruct(t *testing.T) {
+	country := schemaCountry{
+		Name:    "Chile",
+		Capital: &schemaCity{Name: "Santiago", Population: 6310000, Latitude: -33.45},
+		Cities:  []schemaCity{{Name: "Antofagasta", Population: 361873, Latitude: -23.65}},
+		Codes:   map[string]int{"iso": 152},
+		Founded: time.Date(1818, 2, 12, 0, 0, 0, 0, time.UTC),
+		Regions: []*schemaCountry{{Name: "Antofagasta"}},
+		Extra:   []interface{}{"a", true},
+		Ignored: "ignored",
+	}
+	packStr, packErr := PackStruct(country)
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	keyedStr, _ := Pack(country)
+	if len(packStr) >= len(keyedStr) {
+		t.Fatalf("%s isn't shorter than %s", packStr, keyedStr)
+	}
+	var unpacked schemaCountry
+	if err := UnpackStruct(packStr, &unpacked); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked.Name != "Chile" || unpacked.Capital.Population != 6310000 || unpacked.Cities[0].Latitude != -23.65 ||
+		unpacked.Codes["iso"] != 152 || !unpacked.Founded.Equal(country.Founded) || unpacked.Regions[0].Name != "Antofagasta" ||
+		unpacked.Ignored != "" || len(unpacked.Extra.([]interface{})) != 2 {
+		t.Fatalf("unexpected unpacked %+v", unpacked)
+	}
+	// A struct with other fields can't decode it
+	var city schemaCity
+	if err := UnpackStruct(packStr, &city); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected a schema mismatch, got %v", err)
+	}
+	if err := UnpackStruct(keyedStr, &unpacked); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected a schema mismatch, got %v", err)
+	}
+	if _, err := PackStruct([]int{1}); err == nil {
+		t.Fatal("expected a struct to be required")
+	}
+	// The fingerprint changes with the names, the order and the types of the fields
+	type cityRenamed struct {
+		Title      string  `json:"title"`
+		Population int     `json:"population"`
+		Latitude   float64 `json:"latitude"`
+	}
+	type cityReordered struct {
+		Population int     `json:"population"`
+		Name       string  `json:"name"`
+		Latitude   float64 `json:"latitude"`
+	}
+	type cityRetyped struct {
+		Name       string `json:"name"`
+		Population int    `json:"population"`
+		Latitude   string `json:"latitude"`
+	}
+	type cityCopy struct {
+		Name       string  `json:"name"`
+		Population int64   `json:"population"`
+		Latitude   float32 `json:"latitude"`
+	}
+	fingerprints := make(map[string]bool)
+	for _, v := range []interface{}{schemaCity{}, cityRenamed{}, cityReordered{}, cityRetyped{}} {
+		schema, _ := SchemaOf(v)
+		fingerprints[schema.Fingerprint()] = true
+	}
+	if len(fingerprints) != 4 {
+		t.Fatal("the fingerprints aren't distinct")
+	}
+	// The map key type is part of the fingerprint
+	stringKeys, _ := SchemaOf(struct {
+		Codes map[string]int `json:"codes"`
+	}{})
+	intKeys, _ := SchemaOf(struct {
+		Codes map[int]int `json:"codes"`
+	}{})
+	if stringKeys.Fingerprint() == intKeys.Fingerprint() {
+		t.Fatal("map[string]int and map[int]int have the same fingerprint")
+	}
+	cityStr, _ := PackStruct(&schemaCity{Name: "Arica", Population: 1, Latitude: 0.5})
+	var copied cityCopy
+	if err := UnpackStruct(cityStr, &copied); err != nil || copied.Name != "Arica" || copied.Latitude != 0.5 {
+		t.Fatalf("unexpected copied %+v %v", copied, err)
+	}
+}
+
+// Positional compression of a pointer cycle
+func TestPackStructCycle(t *testing.T) {
+	country := &schemaCountry{Name: "loop"}
+	country.Regions = []*schemaCountry{country}
+	if _, err := PackStruct(country); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
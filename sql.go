@@ -0,0 +1,62 @@
+package gjsonpack
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// Packed 数据库中以 TEXT 等列保存的压缩数据, 实现 driver.Valuer 与 sql.Scanner:
+//
+//	db.Exec("INSERT INTO orders (data) VALUES (?)", gjsonpack.Packed{V: order})
+//	db.QueryRow("SELECT data FROM orders").Scan(&gjsonpack.Packed{V: &order})
+type Packed struct {
+	// V 写入时压缩的值; 读取时 V 为指针则解压到 V 指向的值,
+	// 否则每次读取都解压为新的 interface{} 并写入 V, 同一个 Packed 可以在 rows.Next 的循环中重复使用
+	V interface{}
+	// Null 列是否为 NULL, V 为 nil 或 nil 指针时写入 NULL;
+	// 读取到 NULL 时设置, V 为指针时不修改 V 指向的值, 否则 V 为 nil
+	Null bool
+}
+
+// Value 压缩 V, 实现 driver.Valuer
+func (p Packed) Value() (driver.Value, error) {
+	if p.Null || p.V == nil {
+		return nil, nil
+	}
+	if value := reflect.ValueOf(p.V); value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil, nil
+	}
+	return Pack(p.V)
+}
+
+// Scan 解压 src, 实现 sql.Scanner
+func (p *Packed) Scan(src interface{}) error {
+	isPointer := reflect.ValueOf(p.V).Kind() == reflect.Ptr
+	var packed string
+	switch node := src.(type) {
+	case nil:
+		p.Null = true
+		if !isPointer {
+			p.V = nil
+		}
+		return nil
+	case string:
+		packed = node
+	case []byte:
+		packed = string(node)
+	default:
+		return fmt.Errorf("Cannot scan %T into Packed! ", src)
+	}
+	p.Null = false
+	if isPointer {
+		return Unpack(packed, p.V)
+	}
+	// The value of the previous row is replaced
+	var value interface{}
+	if err := Unpack(packed, &value); err != nil {
+		return err
+	}
+	p.V = value
+	return nil
+}
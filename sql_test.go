@@ -0,0 +1,204 @@
+package gjsonpack
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver 测试用的数据库驱动, 只有一张单列的表:
+// "INSERT" 插入一行, "SELECT" 以 string 读取所有行, "SELECT BYTES" 以 []byte 读取
+type fakeDriver struct {
+	mu   sync.Mutex
+	rows []driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	if s.query == "INSERT" {
+		return 1
+	}
+	return 0
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query != "INSERT" {
+		return nil, errors.New("unsupported query " + s.query)
+	}
+	s.conn.driver.mu.Lock()
+	defer s.conn.driver.mu.Unlock()
+	s.conn.driver.rows = append(s.conn.driver.rows, args[0])
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "SELECT") {
+		return nil, errors.New("unsupported query " + s.query)
+	}
+	s.conn.driver.mu.Lock()
+	defer s.conn.driver.mu.Unlock()
+	rows := make([]driver.Value, len(s.conn.driver.rows))
+	for i, row := range s.conn.driver.rows {
+		if str, isStr := row.(string); isStr && s.query == "SELECT BYTES" {
+			row = []byte(str)
+		}
+		rows[i] = row
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows []driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"data"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// _openFakeDB 打开一个空的测试数据库
+func _openFakeDB(t *testing.T, name string) (*sql.DB, *fakeDriver) {
+	fake := &fakeDriver{}
+	sql.Register(name, fake)
+	db, dbErr := sql.Open(name, "")
+	if dbErr != nil {
+		t.Fatal(dbErr)
+	}
+	return db, fake
+}
+
+type sqlOrder struct {
+	ID    int64    `json:"id"`
+	Items []string `json:"items"`
+}
+
+// Packed columns through database/sql
+func TestPackedSQL(t *testing.T) {
+	db, fake := _openFakeDB(t, "gjsonpack-fake")
+	defer db.Close()
+	var nilOrder *sqlOrder
+	inserts := []Packed{
+		{V: sqlOrder{ID: 1, Items: []string{"apple", "pear"}}},
+		{V: nil},
+		{V: nilOrder},
+		{V: map[string]interface{}{"id": 2}, Null: true},
+	}
+	for _, insert := range inserts {
+		if _, err := db.Exec("INSERT", insert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.rows[0] != "id|items|apple|pear^1^^$0|4|1|@2|3]]" || fake.rows[1] != nil || fake.rows[2] != nil || fake.rows[3] != nil {
+		t.Fatalf("unexpected rows %#v", fake.rows)
+	}
+	for _, query := range []string{"SELECT", "SELECT BYTES"} {
+		rows, rowsErr := db.Query(query)
+		if rowsErr != nil {
+			t.Fatal(rowsErr)
+		}
+		var orders []sqlOrder
+		var nulls int
+		for rows.Next() {
+			order := sqlOrder{ID: -1}
+			column := Packed{V: &order}
+			if err := rows.Scan(&column); err != nil {
+				t.Fatal(err)
+			}
+			if column.Null {
+				if order.ID != -1 {
+					t.Fatalf("NULL modified the order %v", order)
+				}
+				nulls++
+				continue
+			}
+			orders = append(orders, order)
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if nulls != 3 || len(orders) != 1 || orders[0].ID != 1 || strings.Join(orders[0].Items, ",") != "apple,pear" {
+			t.Fatalf("%s: unexpected orders %v, %d NULLs", query, orders, nulls)
+		}
+	}
+	// Without a pointer every row is unpacked into a new interface{}
+	if _, err := db.Exec("INSERT", Packed{V: []string{"banana"}}); err != nil {
+		t.Fatal(err)
+	}
+	rows, rowsErr := db.Query("SELECT BYTES")
+	if rowsErr != nil {
+		t.Fatal(rowsErr)
+	}
+	defer rows.Close()
+	var column Packed
+	var scanned []interface{}
+	for rows.Next() {
+		if err := rows.Scan(&column); err != nil {
+			t.Fatal(err)
+		}
+		if column.Null != (column.V == nil) {
+			t.Fatalf("unexpected column %#v", column)
+		}
+		scanned = append(scanned, column.V)
+	}
+	if len(scanned) != 5 || scanned[1] != nil || scanned[4].([]interface{})[0] != "banana" {
+		t.Fatalf("unexpected rows %#v", scanned)
+	}
+	if order, isMap := scanned[0].(map[string]interface{}); !isMap || order["id"] != float64(1) {
+		t.Fatalf("unexpected first row %#v", scanned[0])
+	}
+}
+
+// Unsupported and bad columns
+func TestPackedScanError(t *testing.T) {
+	var order sqlOrder
+	if err := (&Packed{V: &order}).Scan(int64(1)); err == nil {
+		t.Fatal("expected an unsupported column type")
+	}
+	if err := (&Packed{V: &order}).Scan("x"); err == nil {
+		t.Fatal("expected a bad packed column")
+	}
+}
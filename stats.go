@@ -0,0 +1,203 @@
+package gjsonpack
+
+import (
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Stats 压缩统计, 在压缩过程中收集
+type Stats struct {
+	// InputSize 输入数据的紧凑 JSON 字节数, 图模式下存在循环引用时为 -1
+	InputSize int64
+	// PackedSize 输出的字节数
+	PackedSize int64
+	// Strings、Integers、Floats 各字典的条目数
+	Strings  int64
+	Integers int64
+	Floats   int64
+	// EscapeBytes 字符串转义增加的字节数
+	EscapeBytes int64
+	// Tokens 结构区的 token 数
+	Tokens int64
+	// MaxDepth 对象、数组的最大嵌套深度, 顶层为单个值时为 0
+	MaxDepth int64
+}
+
+// Ratio 输出与输入的字节数之比, 无法计算时为 0
+func (s *Stats) Ratio() float64 {
+	if s.InputSize <= 0 {
+		return 0
+	}
+	return float64(s.PackedSize) / float64(s.InputSize)
+}
+
+// PackWithStats 按选项压缩 json 参数中的数据并返回压缩统计
+func PackWithStats(json interface{}, opts ...PackOption) (string, *Stats, error) {
+	options := NewPackOptions(opts...)
+	stats := &Stats{}
+	packed, features, packedErr := _packBodyStats(json, &options, stats)
+	if packedErr != nil {
+		return "", nil, packedErr
+	}
+	wrapped, wrappedErr := _wrapPacked(packed, packedHeader{Features: features}, &options)
+	if wrappedErr != nil {
+		return "", nil, wrappedErr
+	}
+	stats.PackedSize = int64(len(wrapped))
+	return wrapped, stats, nil
+}
+
+// statsState 压缩过程中收集统计的状态
+type statsState struct {
+	stats *Stats
+	// The nesting depth of the current item
+	depth int64
+	// The JSON sizes of the string and float dictionary entries
+	stringSizes []int64
+	floatSizes  []int64
+	// The JSON sizes of the closed anchors
+	anchorSizes map[*astAnchor]int64
+	cyclic      bool
+}
+
+// _newStatsState 创建收集到 stats 的统计状态, depth 为起始的嵌套深度
+func _newStatsState(stats *Stats, depth int64) *statsState {
+	return &statsState{stats: stats, depth: depth, anchorSizes: make(map[*astAnchor]int64)}
+}
+
+// finish 压缩结束后从字典记录条目数
+func (s *statsState) finish(state *packState) {
+	dictionaryObj := state.dictionaryObj
+	s.stats.Strings = dictionaryObj.Strings.Len()
+	s.stats.Integers = dictionaryObj.Integers.Len()
+	s.stats.Floats = dictionaryObj.Floats.Len()
+	s.stats.EscapeBytes = state.escapeBytes
+	if s.cyclic {
+		s.stats.InputSize = -1
+	}
+}
+
+// merge 合并并行压缩中一块的统计
+func (s *statsState) merge(chunk *statsState) {
+	s.stats.Tokens += chunk.stats.Tokens
+	s.stats.InputSize += chunk.stats.InputSize
+	if chunk.stats.MaxDepth > s.stats.MaxDepth {
+		s.stats.MaxDepth = chunk.stats.MaxDepth
+	}
+}
+
+// addToken 统计一个 JSON 字节数为 size 的 token
+func (state *packState) addToken(size int64) {
+	if state.stats == nil {
+		return
+	}
+	state.stats.stats.Tokens++
+	state.stats.stats.InputSize += size
+}
+
+// literal 统计并返回 true、false、null、undefined 或空字符串
+func (state *packState) literal(info astInfo) astInfo {
+	switch {
+	case info.Type == "empty":
+		state.addToken(2)
+	case info.Index == tokenFalse:
+		state.addToken(5)
+	default:
+		// true, null and undefined
+		state.addToken(4)
+	}
+	return info
+}
+
+// enterContainer 统计进入对象或数组的嵌套深度
+func (state *packState) enterContainer() {
+	if state.stats == nil {
+		return
+	}
+	state.stats.depth++
+	if state.stats.depth > state.stats.stats.MaxDepth {
+		state.stats.stats.MaxDepth = state.stats.depth
+	}
+}
+
+// leaveContainer 统计离开语法树为 node 的对象或数组
+func (state *packState) leaveContainer(node []interface{}) {
+	if state.stats == nil {
+		return
+	}
+	state.stats.depth--
+	// The symbol and the end
+	state.stats.stats.Tokens += 2
+	// The brackets, and a comma between items or a colon or comma between the keys and values
+	size := int64(2)
+	if len(node) > 1 {
+		size += int64(len(node) - 2)
+	}
+	state.stats.stats.InputSize += size
+}
+
+// addReference 统计对 anchor 的引用
+func (state *packState) addReference(anchor *astAnchor) {
+	if state.stats == nil {
+		return
+	}
+	if !anchor.Referenced {
+		// The anchor symbol '&'
+		state.stats.stats.Tokens++
+	}
+	// The reference symbol '~' and the anchor index
+	state.stats.stats.Tokens += 2
+	size, closed := state.stats.anchorSizes[anchor]
+	if !closed {
+		state.stats.cyclic = true
+		return
+	}
+	state.stats.stats.InputSize += size
+}
+
+// _jsonStringSize 与 encoding/json 一致的字符串 JSON 字节数
+func _jsonStringSize(str string) int64 {
+	size := int64(2)
+	for i := 0; i < len(str); {
+		if c := str[i]; c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\' || c == '\b' || c == '\f' || c == '\n' || c == '\r' || c == '\t':
+				size += 2
+			case c < ' ' || c == '<' || c == '>' || c == '&':
+				// Written as \u00XX
+				size += 6
+			default:
+				size++
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(str[i:])
+		if r == utf8.RuneError && n == 1 {
+			// Replaced by U+FFFD
+			size += 3
+		} else if r == '\u2028' || r == '\u2029' {
+			// Written as \u2028 or \u2029
+			size += 6
+		} else {
+			size += int64(n)
+		}
+		i += n
+	}
+	return size
+}
+
+// _jsonFloatSize 与 encoding/json 一致的浮点数 JSON 字节数
+func _jsonFloatSize(number float64) int64 {
+	format := byte('f')
+	if abs := math.Abs(number); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	formatted := strconv.FormatFloat(number, format, -1, 64)
+	if n := len(formatted); format == 'e' && n >= 4 && formatted[n-4:n-1] == "e-0" {
+		// encoding/json writes e-07 as e-7
+		return int64(n - 1)
+	}
+	return int64(len(formatted))
+}
@@ -0,0 +1,83 @@
+package gjsonpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// The statistics match the packed data
+func TestPackWithStats(t *testing.T) {
+	value := map[string]interface{}{
+		"type":  "hello world",
+		"list":  []interface{}{"a|b", 1, 1, 2.5, true, false, nil, "", []interface{}{}, map[string]interface{}{}},
+		"deep":  map[string]interface{}{"deeper": []interface{}{map[string]interface{}{"deepest": "<&>"}}},
+		"empty": "",
+	}
+	packStr, stats, packErr := PackWithStats(value, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	plainStr, _ := PackWithOptions(value, WithSortedKeys())
+	if packStr != plainStr {
+		t.Fatalf("%s isn't %s", packStr, plainStr)
+	}
+	jsonBytes, _ := json.Marshal(value)
+	inspection, _ := Inspect(packStr)
+	if stats.InputSize != int64(len(jsonBytes)) || stats.PackedSize != int64(len(packStr)) ||
+		stats.Tokens != int64(inspection.Tokens) || stats.MaxDepth != 4 {
+		t.Fatalf("unexpected stats %+v, JSON %d bytes, %d tokens", stats, len(jsonBytes), inspection.Tokens)
+	}
+	if stats.Strings != int64(len(inspection.Strings)) || stats.Integers != 2 || stats.Floats != 1 {
+		t.Fatalf("unexpected dictionary stats %+v", stats)
+	}
+	// "a|b" becomes "a%7Cb", the other strings have no escape bytes
+	if stats.EscapeBytes != 2 || stats.Ratio() <= 0 {
+		t.Fatalf("unexpected escape bytes %d", stats.EscapeBytes)
+	}
+	// A top-level scalar
+	_, scalarStats, _ := PackWithStats("hello")
+	if scalarStats.MaxDepth != 0 || scalarStats.Tokens != 1 || scalarStats.InputSize != 7 {
+		t.Fatalf("unexpected scalar stats %+v", scalarStats)
+	}
+}
+
+// Shared pointers count their JSON size, cycles have none
+func TestPackWithStatsGraph(t *testing.T) {
+	shared := &graphNode{Name: "shared"}
+	tree := &graphNode{Name: "root", Children: []*graphNode{shared, shared}}
+	packStr, stats, packErr := PackWithStats(tree, WithGraph(), WithCompression(CompressionFlate))
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	// Nil slices pack as empty arrays, so compare with the unpacked JSON
+	jsonBytes, _ := UnpackToBytes(packStr)
+	if bytes.Contains(jsonBytes, []byte("parent")) {
+		t.Fatalf("the empty parents aren't omitted: %s", jsonBytes)
+	}
+	inspection, _ := Inspect(packStr)
+	if stats.InputSize != int64(len(jsonBytes)) || stats.Tokens != int64(inspection.Tokens) || stats.PackedSize != int64(len(packStr)) {
+		t.Fatalf("unexpected stats %+v, JSON %d bytes, %d tokens", stats, len(jsonBytes), inspection.Tokens)
+	}
+	shared.Parent = tree
+	_, cyclicStats, _ := PackWithStats(tree, WithGraph())
+	if cyclicStats.InputSize != -1 || cyclicStats.Ratio() != 0 {
+		t.Fatalf("unexpected cyclic stats %+v", cyclicStats)
+	}
+}
+
+// The input size follows the escaping and number formatting of encoding/json
+func TestPackWithStatsJSONSize(t *testing.T) {
+	value := []interface{}{
+		"<a href=\"x\">&</a>", "tab\tnew\nline\\", "\x01\x7f", "héllo, 世界", "  ", "bad \xff utf-8",
+		1e21, 1e-7, 1.5e-300, -0.000001, 123456.789, -9223372036854775808, 1e20,
+	}
+	_, stats, packErr := PackWithStats(value)
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	jsonBytes, _ := json.Marshal(value)
+	if stats.InputSize != int64(len(jsonBytes)) {
+		t.Fatalf("input size %d, JSON %d bytes: %s", stats.InputSize, len(jsonBytes), jsonBytes)
+	}
+}
@@ -0,0 +1,45 @@
+// Records the test vectors of the JavaScript compatibility mode with the
+// jsonpack package pinned in package.json:
+//
+//   cd testdata/jsonpack && npm install && node record.js > vectors.json
+//
+// It fails when the package is missing or has another version.
+'use strict';
+
+var VERSION = require('./package.json').dependencies.jsonpack;
+
+var jsonpack, version;
+try {
+	jsonpack = require('jsonpack');
+	version = require('jsonpack/package.json').version;
+} catch (e) {
+	process.stderr.write('record.js: cannot require jsonpack, run "npm install" first: ' + e.message + '\n');
+	process.exit(1);
+}
+if (version !== VERSION) {
+	process.stderr.write('record.js: expected jsonpack ' + VERSION + ', got ' + version + '\n');
+	process.exit(1);
+}
+
+var inputs = {
+	'world': '{"type":"world","name":"earth","children":[{"type":"continent","name":"America","children":[{"type":"country","name":"Chile","children":[{"type":"commune","name":"Antofagasta"}]}]},{"type":"continent","name":"Europe"}]}',
+	'escapes': '{"a b":"c+d","e|f":"g^h","i%j":"a b","k":["a b","a b","c+d"]}',
+	'integers': '[1,1,2,-1,0,-0,35,36,1296,9007199254740991,-9007199254740991,1.0,2e3]',
+	'floats': '[0.5,0.5,-0.25,0.1,0.30000000000000004,123.456,1e-7,1.5e-7,0.000001,1234567.125,-2.5e-10]',
+	'key order': '{"b":1,"2":2,"a":3,"1":4,"01":5,"-1":6,"4294967295":7,"4294967294":8}',
+	'duplicate keys': '{"a":1,"b":2,"a":3}',
+	'scalars': '{"t":true,"f":false,"n":null,"e":"","z":0}',
+	'empty': '{"o":{},"a":[],"n":[[],{}],"":""}',
+	'unicode': '{"名前":"東京","emoji":"café ☕","escaped":"\\u00e9\\n\\t\\""}',
+	'top level array': '[1,"a",[true,null],{"a":"a"}]',
+	'top level string': '"hello world"',
+	'top level number': '42',
+	'top level float': '2.5',
+	'top level null': 'null'
+};
+
+var vectors = [];
+Object.keys(inputs).forEach(function(name) {
+	vectors.push({ name : name, json : inputs[name], packed : jsonpack.pack(inputs[name]) });
+});
+process.stdout.write(JSON.stringify({ jsonpack : version, vectors : vectors }, null, '\t') + '\n');
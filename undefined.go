@@ -0,0 +1,30 @@
+package gjsonpack
+
+// UndefinedType JavaScript 中 undefined 的类型
+type UndefinedType struct{}
+
+// Undefined JavaScript 中的 undefined, 压缩为 -5,
+// 可以放在 slice 中表示稀疏数组的空位
+var Undefined = UndefinedType{}
+
+// MarshalJSON 与 JSON.stringify 一致, undefined 转换为 null
+func (UndefinedType) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// String undefined 的文本形式
+func (UndefinedType) String() string {
+	return "undefined"
+}
+
+// UndefinedMode 解压 undefined 的方式
+type UndefinedMode int
+
+const (
+	// UndefinedAsNull 解压为 null
+	UndefinedAsNull UndefinedMode = iota
+	// UndefinedAsValue 解压为 Undefined
+	UndefinedAsValue
+	// UndefinedOmit 省略对象中值为 undefined 的键, 数组中的 undefined 与 JSON.stringify 一致解压为 null
+	UndefinedOmit
+)
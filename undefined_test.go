@@ -0,0 +1,90 @@
+package gjsonpack
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Compression and decompression of undefined
+func TestUndefined(t *testing.T) {
+	jsonMap := map[string]interface{}{
+		"a":      Undefined,
+		"sparse": []interface{}{1, Undefined, 3},
+	}
+	packStr, packErr := PackWithOptions(jsonMap, WithSortedKeys())
+	if packErr != nil {
+		t.Fatal(packErr)
+	}
+	if packStr != "a|sparse^1|3^^$0|-5|1|@2|-5|3]]" {
+		t.Fatalf("unexpected packStr %s", packStr)
+	}
+	expected := map[UndefinedMode]string{
+		UndefinedAsNull:  `{"a":null,"sparse":[1,null,3]}`,
+		UndefinedAsValue: `{"a":null,"sparse":[1,null,3]}`,
+		UndefinedOmit:    `{"sparse":[1,null,3]}`,
+	}
+	for mode, expectedJSON := range expected {
+		jsonStr, unPackErr := NewUnpackOptions(WithUndefined(mode)).UnpackToStr(packStr)
+		if unPackErr != nil {
+			t.Fatal(unPackErr)
+		}
+		if jsonStr != expectedJSON {
+			t.Fatalf("%d: unexpected json %s", mode, jsonStr)
+		}
+	}
+	unpacked := make(map[string]interface{}, 0)
+	if err := UnpackWithOptions(packStr, &unpacked, WithUndefined(UndefinedAsValue)); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked["a"] != Undefined || unpacked["sparse"].([]interface{})[1] != Undefined {
+		t.Fatalf("unexpected unpacked %v", unpacked)
+	}
+	var typed struct {
+		A      *string `json:"a"`
+		Sparse []int   `json:"sparse"`
+	}
+	if err := UnpackWithOptions(packStr, &typed, WithUndefined(UndefinedAsValue)); err != nil {
+		t.Fatal(err)
+	}
+	if typed.A != nil || len(typed.Sparse) != 3 || typed.Sparse[2] != 3 {
+		t.Fatalf("unexpected typed %+v", typed)
+	}
+}
+
+// UseNumber applies with or without Undefined in the result
+func TestUndefinedUseNumber(t *testing.T) {
+	packStr, _ := PackWithOptions(map[string]interface{}{"id": int64(9007199254740993), "ratio": 0.5}, WithSortedKeys())
+	withUndefined, _ := PackWithOptions(map[string]interface{}{"id": int64(9007199254740993), "hole": Undefined}, WithSortedKeys())
+	for _, packed := range []string{packStr, withUndefined} {
+		var unpacked map[string]interface{}
+		if err := UnpackWithOptions(packed, &unpacked, WithUndefined(UndefinedAsValue), WithUseNumber()); err != nil {
+			t.Fatal(err)
+		}
+		if unpacked["id"] != json.Number("9007199254740993") {
+			t.Fatalf("%s: unexpected id %#v", packed, unpacked["id"])
+		}
+		if _, hasHole := unpacked["hole"]; hasHole && unpacked["hole"] != Undefined {
+			t.Fatalf("%s: unexpected hole %#v", packed, unpacked["hole"])
+		}
+	}
+	// Graph mode also decodes by reflection
+	shared := map[string]interface{}{"id": int64(9007199254740993)}
+	graphStr, _ := PackWithOptions([]interface{}{&shared, &shared}, WithGraph())
+	var graph []interface{}
+	if err := UnpackWithOptions(graphStr, &graph, WithUseNumber()); err != nil {
+		t.Fatal(err)
+	}
+	if graph[1].(map[string]interface{})["id"] != json.Number("9007199254740993") {
+		t.Fatalf("unexpected graph %#v", graph)
+	}
+	var typed struct {
+		ID   json.Number `json:"id"`
+		Hole interface{} `json:"hole"`
+	}
+	if err := UnpackWithOptions(withUndefined, &typed, WithUndefined(UndefinedAsValue)); err != nil {
+		t.Fatal(err)
+	}
+	if typed.ID != "9007199254740993" || typed.Hole != Undefined {
+		t.Fatalf("unexpected typed %+v", typed)
+	}
+}